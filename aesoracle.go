@@ -4,54 +4,168 @@ import (
 	"bytes"
 	"crypto/aes"
 	"errors"
-	"math/rand"
+	"fmt"
+	"io"
 
 	"github.com/cespare/matasano/pkcs7"
 )
 
-// AESOracle implements the oracle function described at http://cryptopals.com/sets/2/challenges/11/. It
-// returns whether it used ECB so we can check against the detector.
-func AESOracle(plaintext []byte) (encrypted []byte, err error, ecb bool) {
-	before := RandomSlice(rand.Intn(6) + 5)
-	after := RandomSlice(rand.Intn(6) + 5)
-	result := make([]byte, len(plaintext)+len(before)+len(after))
-	copy(result, before)
-	copy(result[len(before):], plaintext)
-	copy(result[len(before)+len(plaintext):], after)
-	result = pkcs7.Pad(result, 16)
-
-	key := RandomSlice(16)
-	iv := RandomSlice(16)
-	cipher, err := aes.NewCipher([]byte(key))
+// A Mode is a block cipher mode of operation.
+type Mode int
+
+const (
+	ECB Mode = iota
+	CBC
+	CTR
+	OFB
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ECB:
+		return "ECB"
+	case CBC:
+		return "CBC"
+	case CTR:
+		return "CTR"
+	case OFB:
+		return "OFB"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// A WeightedMode is a Mode that an AESOracle chooses with probability proportional to Weight.
+type WeightedMode struct {
+	Mode   Mode
+	Weight float64
+}
+
+// EqualModes returns modes with equal weights.
+func EqualModes(modes ...Mode) []WeightedMode {
+	weighted := make([]WeightedMode, len(modes))
+	for i, m := range modes {
+		weighted[i] = WeightedMode{Mode: m, Weight: 1}
+	}
+	return weighted
+}
+
+// AESOracle is a configurable version of the oracle function described at
+// http://cryptopals.com/sets/2/challenges/11/. For each query it picks a new random key and IV, surrounds the
+// plaintext with random bytes, and encrypts it with a randomly chosen mode. ECB and CBC pad the input with
+// PKCS#7; CTR and OFB don't need to.
+type AESOracle struct {
+	KeySize              int // 16, 24, or 32
+	PrefixMin, PrefixMax int // The range (inclusive) of the number of random bytes before the plaintext
+	SuffixMin, SuffixMax int // Likewise for the random bytes after the plaintext
+	Modes                []WeightedMode
+	Rand                 io.Reader // Source of the key and all random choices (crypto/rand if nil)
+}
+
+// NewAESOracle returns an oracle configured as in the challenge: 16-byte keys, 5-10 bytes before and after,
+// and ECB or CBC with equal probability.
+func NewAESOracle(rand io.Reader) *AESOracle {
+	return &AESOracle{
+		KeySize:   16,
+		PrefixMin: 5,
+		PrefixMax: 10,
+		SuffixMin: 5,
+		SuffixMax: 10,
+		Modes:     EqualModes(ECB, CBC),
+		Rand:      rand,
+	}
+}
+
+// An AESResult is the outcome of an AESOracle query, including the secrets (so we can check a detector).
+type AESResult struct {
+	Ciphertext []byte
+	Mode       Mode
+	Key        []byte
+	IV         []byte // Unused for ECB
+	PrefixLen  int
+	SuffixLen  int
+}
+
+func (o *AESOracle) Query(plaintext []byte) (*AESResult, error) {
+	switch o.KeySize {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("bad key size %d", o.KeySize)
+	}
+	if o.PrefixMin < 0 || o.PrefixMax < o.PrefixMin || o.SuffixMin < 0 || o.SuffixMax < o.SuffixMin {
+		return nil, errors.New("bad prefix or suffix range")
+	}
+	mode, err := o.chooseMode()
 	if err != nil {
-		return nil, err, false
+		return nil, err
 	}
-	mode := rand.Intn(2)
-	encrypter := NewECBEncrypter(cipher)
-	if mode == 1 {
-		encrypter = NewCBCEncrypter(cipher, iv)
+	key := RandomBytes(o.Rand, o.KeySize)
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	r := &AESResult{
+		Mode:      mode,
+		Key:       key,
+		IV:        RandomBytes(o.Rand, aes.BlockSize),
+		PrefixLen: o.PrefixMin + RandomIntn(o.Rand, o.PrefixMax-o.PrefixMin+1),
+		SuffixLen: o.SuffixMin + RandomIntn(o.Rand, o.SuffixMax-o.SuffixMin+1),
 	}
+	input := RandomBytes(o.Rand, r.PrefixLen)
+	input = append(input, plaintext...)
+	input = append(input, RandomBytes(o.Rand, r.SuffixLen)...)
 
-	encrypter.CryptBlocks(result, result)
-	return result, nil, (mode == 0)
-}
-
-// IsECB implements the ECB detection function described at http://cryptopals.com/sets/2/challenges/11/.
-// Assumes that enc is the output of the oracle when the input is many identical bytes.
-func IsECB(enc []byte, blockSize int) bool {
-	// Check if blocks 3 - 6 are the same (which they should be for ECB).
-	if len(enc) < blockSize*6 {
-		panic("Not enough input to detect ECB.")
-	}
-	for j := 0; j < blockSize; j++ {
-		baseBlock := 3
-		for blockIdx := 4; blockIdx <= 6; blockIdx++ {
-			if enc[blockIdx*blockSize+j] != enc[baseBlock*blockSize+j] {
-				return false
-			}
+	switch mode {
+	case ECB, CBC:
+		input = pkcs7.Pad(input, aes.BlockSize)
+		encrypter := NewECBEncrypter(cipher)
+		if mode == CBC {
+			encrypter = NewCBCEncrypter(cipher, r.IV)
+		}
+		encrypter.CryptBlocks(input, input)
+	case CTR:
+		NewCTR(cipher, r.IV).XORKeyStream(input, input)
+	case OFB:
+		NewOFB(cipher, r.IV).XORKeyStream(input, input)
+	default:
+		return nil, fmt.Errorf("unsupported mode %s", mode)
+	}
+	r.Ciphertext = input
+	return r, nil
+}
+
+// Encrypt returns just the ciphertext from Query, so that an AESOracle can be used as an Oracle.
+func (o *AESOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	r, err := o.Query(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return r.Ciphertext, nil
+}
+
+func (o *AESOracle) chooseMode() (Mode, error) {
+	var total float64
+	for _, m := range o.Modes {
+		if m.Weight < 0 {
+			return 0, errors.New("negative mode weight")
+		}
+		total += m.Weight
+	}
+	if total == 0 {
+		return 0, errors.New("no modes to choose from")
+	}
+	x := RandomFloat(o.Rand) * total
+	for _, m := range o.Modes {
+		if x < m.Weight {
+			return m.Mode, nil
+		}
+		x -= m.Weight
+	}
+	// Floating-point rounding; pick the last mode with any weight.
+	for i := len(o.Modes) - 1; ; i-- {
+		if o.Modes[i].Weight > 0 {
+			return o.Modes[i].Mode, nil
 		}
 	}
-	return true
 }
 
 // AESOracle2 is an instance of the oracle described at http://cryptopals.com/sets/2/challenges/12/.
@@ -60,8 +174,9 @@ type AESOracle2 struct {
 	key        []byte
 }
 
-func NewAESOracle2(ciphertext []byte) *AESOracle2 {
-	return &AESOracle2{ciphertext, RandomSlice(16)}
+// NewAESOracle2 creates an oracle with a key read from rand (crypto/rand if nil).
+func NewAESOracle2(rand io.Reader, ciphertext []byte) *AESOracle2 {
+	return &AESOracle2{ciphertext, RandomBytes(rand, 16)}
 }
 
 func (o *AESOracle2) Encrypt(plaintext []byte) ([]byte, error) {
@@ -79,14 +194,15 @@ func (o *AESOracle2) Encrypt(plaintext []byte) ([]byte, error) {
 }
 
 // ECBNextUnknownByte implements one step of the procedure described at
-// http://cryptopals.com/sets/2/challenges/12/.
-func (o *AESOracle2) ECBNextUnknownByte(soFar []byte, blockSize int) (byte, error) {
+// http://cryptopals.com/sets/2/challenges/12/. The oracle must append the unknown bytes to its input and
+// encrypt with ECB (like AESOracle2).
+func ECBNextUnknownByte(oracle Oracle, soFar []byte, blockSize int) (byte, error) {
 	// Padding with offset bytes at the beginning (using a known byte value of len(soFar) < blockSize) will
 	// place exactly one unknown byte at some index (blockSize - 1) (modulo blockSize).
 	offset := blockSize - (len(soFar) % blockSize) - 1
 	input := make([]byte, offset)   // Use a []byte of all 0x0.
 	block := len(soFar) / blockSize // This is the block we care about.
-	encrypted, err := o.Encrypt(input)
+	encrypted, err := oracle.Encrypt(input)
 	if err != nil {
 		return 0, err
 	}
@@ -105,7 +221,7 @@ func (o *AESOracle2) ECBNextUnknownByte(soFar []byte, blockSize int) (byte, erro
 	for i := 0; i < 256; i++ {
 		c := byte(i)
 		testBlock[blockSize-1] = c
-		enc, err := o.Encrypt(testBlock)
+		enc, err := oracle.Encrypt(testBlock)
 		if err != nil {
 			return 0, err
 		}
@@ -114,13 +230,24 @@ func (o *AESOracle2) ECBNextUnknownByte(soFar []byte, blockSize int) (byte, erro
 		}
 	}
 
-	return 0, errors.New("could not determine next unknown byte")
+	return 0, ErrNoMatchingByte
 }
 
+// ErrNoMatchingByte is returned by ECBNextUnknownByte when no byte matches, as happens after the end of the
+// unknown bytes (where the padding changes underneath the attack).
+var ErrNoMatchingByte = errors.New("could not determine next unknown byte")
+
+// An Oracle encrypts attacker-chosen plaintext under some secret key (and perhaps along with some secret
+// data). See oracle.go for wrappers that instrument an Oracle.
 type Oracle interface {
 	Encrypt([]byte) ([]byte, error)
 }
 
+// OracleFunc adapts an ordinary function to an Oracle.
+type OracleFunc func([]byte) ([]byte, error)
+
+func (f OracleFunc) Encrypt(plaintext []byte) ([]byte, error) { return f(plaintext) }
+
 func DetermineBlockSize(oracle Oracle) (int, error) {
 	encSize := -1
 	blockSize := 0
@@ -150,12 +277,13 @@ type AESOracle3 struct {
 	prefix     []byte
 }
 
-func NewAESOracle3(ciphertext []byte) *AESOracle3 {
+// NewAESOracle3 creates an oracle with a key and prefix read from rand (crypto/rand if nil).
+func NewAESOracle3(rand io.Reader, ciphertext []byte) *AESOracle3 {
 	return &AESOracle3{
 		ciphertext: ciphertext,
-		key:        RandomSlice(16),
+		key:        RandomBytes(rand, 16),
 		// 10 - 30 random bytes
-		prefix: RandomSlice(rand.Intn(21) + 10),
+		prefix: RandomBytes(rand, RandomIntn(rand, 21)+10),
 	}
 }
 
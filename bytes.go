@@ -1,10 +1,14 @@
 package matasano
 
 import (
+	crand "crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
-	"math/rand"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"sync"
 )
 
 func BytesToHex(b []byte) string {
@@ -15,6 +19,24 @@ func HexToBytes(h string) ([]byte, error) {
 	return hex.DecodeString(h)
 }
 
+// MustHexToBytes is like HexToBytes, but panics if h isn't valid hex. It's for constants.
+func MustHexToBytes(h string) []byte {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// MustHexToInt parses h as a (big-endian) hex integer, panicking if it isn't valid hex. It's for constants.
+func MustHexToInt(h string) *big.Int {
+	n, ok := new(big.Int).SetString(h, 16)
+	if !ok {
+		panic("bad hex constant: " + h)
+	}
+	return n
+}
+
 func BytesToBase64(b []byte) string {
 	return base64.StdEncoding.EncodeToString(b)
 }
@@ -34,10 +56,72 @@ func Xor(buf1, buf2 []byte) ([]byte, error) {
 	return result, nil
 }
 
-func RandomSlice(length int) []byte {
+// The functions that need randomness take an io.Reader as the source. A nil source means crypto/rand, which
+// is what keys should use; for reproducible runs, pass a SeededRand instead.
+
+// SeededRand returns a deterministic source of randomness. It is safe for concurrent use (but of course the
+// output is then only deterministic if the order of the reads is).
+func SeededRand(seed int64) io.Reader {
+	return &lockedReader{r: mrand.New(mrand.NewSource(seed))}
+}
+
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(b)
+}
+
+// RandOrDefault returns rand, or crypto/rand if it's nil.
+func RandOrDefault(rand io.Reader) io.Reader {
+	if rand == nil {
+		return crand.Reader
+	}
+	return rand
+}
+
+// RandomBytes returns length bytes read from rand. It panics if rand fails (crypto/rand and SeededRand
+// don't).
+func RandomBytes(rand io.Reader, length int) []byte {
 	random := make([]byte, length)
-	for i := range random {
-		random[i] = byte(rand.Intn(256))
+	if _, err := io.ReadFull(RandOrDefault(rand), random); err != nil {
+		panic(err)
 	}
 	return random
 }
+
+// RandomIntn returns a uniformly random int in [0, n) using rand. It panics if rand fails.
+func RandomIntn(rand io.Reader, n int) int {
+	i, err := crand.Int(RandOrDefault(rand), big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
+
+// RandomNonzero returns a uniformly random integer in [1, n) using rand. That's what a private exponent or
+// nonce modulo n needs.
+func RandomNonzero(rand io.Reader, n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, errors.New("modulus too small")
+	}
+	i, err := crand.Int(RandOrDefault(rand), new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return i.Add(i, big.NewInt(1)), nil
+}
+
+// RandomFloat returns a uniformly random float64 in [0, 1) using rand. It panics if rand fails.
+func RandomFloat(rand io.Reader) float64 {
+	return float64(RandomIntn(rand, 1<<53)) / (1 << 53)
+}
+
+// RandomSlice returns length bytes from crypto/rand.
+func RandomSlice(length int) []byte {
+	return RandomBytes(nil, length)
+}
@@ -0,0 +1,75 @@
+package cbcmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/pkcs7"
+)
+
+// ForgeIV returns the IV that gives newMsg the same CBC-MAC that msg has with iv, where newMsg differs from
+// msg only in the first block. If the verifier accepts the IV from the sender, whoever controls the IV
+// controls the first block.
+func ForgeIV(iv, msg, newMsg []byte) ([]byte, error) {
+	bs := len(iv)
+	if len(msg) < bs || len(newMsg) != len(msg) || string(msg[bs:]) != string(newMsg[bs:]) {
+		return nil, errors.New("cbcmac: messages must be the same after the first block")
+	}
+	newIV := make([]byte, bs)
+	for i := range newIV {
+		newIV[i] = iv[i] ^ msg[i] ^ newMsg[i]
+	}
+	return newIV, nil
+}
+
+// Extend returns a message with the same CBC-MAC (under the fixed iv) as msg2, given another message msg1 and
+// its MAC. The result is msg1, its padding, msg2's first block XORed with mac1 (and iv), and the rest of msg2:
+// the MAC of msg1 is the CBC state after its padding, so the XOR puts the state back where msg2's first block
+// would have put it. iv and mac1 must be AES blocks, and msg2 must be at least a block long; a shorter one
+// would be padded differently on its own than at the end of the forgery.
+func Extend(iv, msg1, mac1, msg2 []byte) ([]byte, error) {
+	const bs = aes.BlockSize
+	if len(iv) != bs || len(mac1) != bs {
+		return nil, errors.New("cbcmac: iv and mac1 must be one block long")
+	}
+	if len(msg2) < bs {
+		return nil, errors.New("cbcmac: msg2 must be at least one block long")
+	}
+	forged := pkcs7.Pad(msg1, bs)
+	first := make([]byte, bs)
+	for i := range first {
+		first[i] = msg2[i] ^ mac1[i] ^ iv[i]
+	}
+	forged = append(forged, first...)
+	return append(forged, msg2[bs:]...), nil
+}
+
+// SecondPreimage returns a message that starts with prefix and has the same CBC-MAC as original, for when
+// CBC-MAC with a known key is (mis)used as a hash function. prefix must be a whole number of blocks, and (as
+// with Extend) original must be at least a block long, so that the forgery's padding is the same as the
+// original's. It's like Extend, except that knowing the key means we can compute the state after prefix
+// without padding it.
+func SecondPreimage(b cipher.Block, iv, prefix, original []byte) ([]byte, error) {
+	bs := b.BlockSize()
+	if len(prefix)%bs != 0 {
+		return nil, errors.New("cbcmac: prefix must be a whole number of blocks")
+	}
+	if len(original) < bs {
+		return nil, errors.New("cbcmac: original must be at least one block long")
+	}
+	state := iv
+	if len(prefix) > 0 {
+		encrypted := make([]byte, len(prefix))
+		matasano.NewCBCEncrypter(b, iv).CryptBlocks(encrypted, prefix)
+		state = encrypted[len(encrypted)-bs:]
+	}
+	// The glue block puts the state back to where the original's first block would have put it.
+	glue := make([]byte, bs)
+	for i := range glue {
+		glue[i] = original[i] ^ iv[i] ^ state[i]
+	}
+	forged := append(append([]byte{}, prefix...), glue...)
+	return append(forged, original[bs:]...), nil
+}
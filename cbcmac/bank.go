@@ -0,0 +1,187 @@
+package cbcmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cespare/matasano"
+)
+
+// Bank is the server for the money-transfer API described at http://cryptopals.com/sets/7/challenges/49/.
+// Its clients share its MAC key, but each client only signs requests from its own account, so the bank takes
+// a valid MAC as proof that the account's owner made the request.
+//
+// There are two versions of the API. In the first, a request is
+//
+//	from=#{from_id}&to=#{to_id}&amount=#{amount} || IV || MAC
+//
+// and in the second, which uses a fixed (zero) IV, it's
+//
+//	from=#{from_id}&tx_list=#{to_id}:#{amount};#{to_id}:#{amount}... || MAC
+type Bank struct {
+	block cipher.Block
+
+	mu       sync.Mutex
+	balances map[int]int
+}
+
+var (
+	ErrBadMAC            = errors.New("cbcmac: invalid MAC")
+	ErrBadRequest        = errors.New("cbcmac: malformed request")
+	ErrNoSuchAccount     = errors.New("cbcmac: no such account")
+	ErrInsufficientFunds = errors.New("cbcmac: insufficient funds")
+)
+
+// NewBank creates a bank with a key read from rand (crypto/rand if nil) and the given account balances.
+func NewBank(rand io.Reader, balances map[int]int) (*Bank, error) {
+	block, err := aes.NewCipher(matasano.RandomBytes(rand, 16))
+	if err != nil {
+		return nil, err
+	}
+	b := &Bank{block: block, balances: make(map[int]int)}
+	for id, balance := range balances {
+		b.balances[id] = balance
+	}
+	return b, nil
+}
+
+// Balance returns the balance of an account.
+func (b *Bank) Balance(id int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.balances[id]
+}
+
+func (b *Bank) transfer(from, to, amount int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.balances[from]; !ok {
+		return ErrNoSuchAccount
+	}
+	if _, ok := b.balances[to]; !ok {
+		return ErrNoSuchAccount
+	}
+	if amount < 0 || b.balances[from] < amount {
+		return ErrInsufficientFunds
+	}
+	b.balances[from] -= amount
+	b.balances[to] += amount
+	return nil
+}
+
+// HandleTransfer verifies and carries out a request in the first version of the API.
+func (b *Bank) HandleTransfer(req []byte) error {
+	bs := b.block.BlockSize()
+	if len(req) < 2*bs {
+		return ErrBadRequest
+	}
+	msg, iv, mac := req[:len(req)-2*bs], req[len(req)-2*bs:len(req)-bs], req[len(req)-bs:]
+	if !Verify(b.block, iv, msg, mac) {
+		return ErrBadMAC
+	}
+	params, err := parseParams(string(msg))
+	if err != nil {
+		return err
+	}
+	from, err1 := strconv.Atoi(params["from"])
+	to, err2 := strconv.Atoi(params["to"])
+	amount, err3 := strconv.Atoi(params["amount"])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return ErrBadRequest
+	}
+	return b.transfer(from, to, amount)
+}
+
+// HandleMultiTransfer verifies and carries out a request in the second version of the API. It skips any
+// transactions that it can't parse or carry out (which turns out to be a bad idea) and returns the number
+// that went through.
+func (b *Bank) HandleMultiTransfer(req []byte) (int, error) {
+	bs := b.block.BlockSize()
+	if len(req) < bs {
+		return 0, ErrBadRequest
+	}
+	msg, mac := req[:len(req)-bs], req[len(req)-bs:]
+	if !Verify(b.block, make([]byte, bs), msg, mac) {
+		return 0, ErrBadMAC
+	}
+	params, err := parseParams(string(msg))
+	if err != nil {
+		return 0, err
+	}
+	from, err := strconv.Atoi(params["from"])
+	if err != nil {
+		return 0, ErrBadRequest
+	}
+	n := 0
+	for _, tx := range strings.Split(params["tx_list"], ";") {
+		parts := strings.Split(tx, ":")
+		if len(parts) != 2 {
+			continue
+		}
+		to, err1 := strconv.Atoi(parts[0])
+		amount, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if b.transfer(from, to, amount) == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// parseParams parses "k=v&k=v...". Each pair must have an '='.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(s, "&") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrBadRequest
+		}
+		params[kv[0]] = kv[1]
+	}
+	return params, nil
+}
+
+// A Client makes signed requests from one account.
+type Client struct {
+	block   cipher.Block
+	rand    io.Reader
+	Account int
+}
+
+// Client returns a client for an account that shares the bank's key, using rand (crypto/rand if nil) for
+// IVs.
+func (b *Bank) Client(rand io.Reader, account int) *Client {
+	return &Client{block: b.block, rand: rand, Account: account}
+}
+
+// Transfer makes a request in the first version of the API with a random IV.
+func (c *Client) Transfer(to, amount int) []byte {
+	msg := []byte(fmt.Sprintf("from=%d&to=%d&amount=%d", c.Account, to, amount))
+	iv := matasano.RandomBytes(c.rand, c.block.BlockSize())
+	req := append(msg, iv...)
+	return append(req, MAC(c.block, iv, msg)...)
+}
+
+// A Tx is one transaction in a multi-transfer request.
+type Tx struct {
+	To     int
+	Amount int
+}
+
+// MultiTransfer makes a request in the second version of the API.
+func (c *Client) MultiTransfer(txs []Tx) []byte {
+	list := make([]string, len(txs))
+	for i, tx := range txs {
+		list[i] = fmt.Sprintf("%d:%d", tx.To, tx.Amount)
+	}
+	msg := []byte(fmt.Sprintf("from=%d&tx_list=%s", c.Account, strings.Join(list, ";")))
+	return append(msg, MAC(c.block, make([]byte, c.block.BlockSize()), msg)...)
+}
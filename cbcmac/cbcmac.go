@@ -0,0 +1,23 @@
+// Package cbcmac implements CBC-MAC and CMAC and the attacks on CBC-MAC described at
+// http://cryptopals.com/sets/7/challenges/49/ and http://cryptopals.com/sets/7/challenges/50/.
+package cbcmac
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/pkcs7"
+)
+
+// MAC returns the CBC-MAC of msg: the last block of its CBC encryption with iv, after PKCS#7 padding.
+func MAC(b cipher.Block, iv, msg []byte) []byte {
+	padded := pkcs7.Pad(msg, b.BlockSize())
+	matasano.NewCBCEncrypter(b, iv).CryptBlocks(padded, padded)
+	return padded[len(padded)-b.BlockSize():]
+}
+
+// Verify reports whether mac is the CBC-MAC of msg.
+func Verify(b cipher.Block, iv, msg, mac []byte) bool {
+	return subtle.ConstantTimeCompare(MAC(b, iv, msg), mac) == 1
+}
@@ -0,0 +1,77 @@
+package cbcmac
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+// CMAC returns the AES-CMAC (RFC 4493) of msg, for a cipher with a 128-bit block size. Unlike CBC-MAC, the last
+// block is masked with a key-derived value, which stops a MAC from being used as a CBC state to extend from.
+func CMAC(b cipher.Block, msg []byte) []byte {
+	bs := b.BlockSize()
+	if bs != 16 {
+		panic("cbcmac: CMAC needs a 128-bit block size")
+	}
+	k1, k2 := subkeys(b)
+
+	// Every block but the last is processed as in CBC-MAC. The last is XORed with K1 if it's complete and
+	// padded with 0x80 0x00 ... and XORed with K2 if not.
+	n := (len(msg) + bs - 1) / bs
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, bs)
+	rest := msg[(n-1)*bs:]
+	copy(last, rest)
+	if len(rest) == bs {
+		xorInto(last, k1)
+	} else {
+		last[len(rest)] = 0x80
+		xorInto(last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		xorInto(x, msg[i*bs:(i+1)*bs])
+		b.Encrypt(x, x)
+	}
+	xorInto(x, last)
+	b.Encrypt(x, x)
+	return x
+}
+
+// VerifyCMAC reports whether mac is the CMAC of msg.
+func VerifyCMAC(b cipher.Block, msg, mac []byte) bool {
+	return subtle.ConstantTimeCompare(CMAC(b, msg), mac) == 1
+}
+
+// subkeys derives K1 and K2 from the encryption of the zero block by doubling in GF(2^128).
+func subkeys(b cipher.Block) (k1, k2 []byte) {
+	l := make([]byte, 16)
+	b.Encrypt(l, l)
+	k1 = double(l)
+	k2 = double(k1)
+	return k1, k2
+}
+
+// double multiplies a block by x in GF(2^128) with the RFC 4493 conventions (a left shift, reducing by
+// 0x87).
+func double(in []byte) []byte {
+	out := make([]byte, len(in))
+	for i := range in {
+		out[i] = in[i] << 1
+		if i+1 < len(in) {
+			out[i] |= in[i+1] >> 7
+		}
+	}
+	if in[0]&0x80 != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+func xorInto(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,154 @@
+package matasano
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cespare/matasano/pkcs7"
+)
+
+// CutAndPaste forges ECB-encrypted tokens for services like the profile service at
+// http://cryptopals.com/sets/2/challenges/13/, where we choose part of the plaintext (an email address, say)
+// and the service encrypts it inside some larger encoding. Since ECB encrypts each block independently, any
+// plaintext that can be split into blocks which each appear, block-aligned, in the encoding of some input can
+// be forged by splicing together the corresponding ciphertext blocks.
+//
+// All that CutAndPaste needs to know about the encoding is Encode, so it isn't tied to a particular order of
+// fields or choice of separators, and it copes with encodings that strip or escape parts of the input (a block
+// containing something that gets stripped just has to come from somewhere else). It can't forge a block that
+// appears nowhere and can't be injected, though: for instance, if the new value has to be followed by a
+// separator that the encoding strips from inputs.
+type CutAndPaste struct {
+	BlockSize int
+	// Encode gives the plaintext that the service encrypts for an input (for p13, ProfileFor).
+	Encode func(input string) string
+	// Encrypt queries the service.
+	Encrypt func(input string) ([]byte, error)
+	// Filler is used to pad out inputs to get the alignment right (default 'a').
+	Filler byte
+}
+
+// A blockSource is an input whose (PKCS#7 padded) encoding contains some block at index block.
+type blockSource struct {
+	input string
+	block int
+}
+
+// Forge returns a ciphertext for edit(Encode(x)), for some input x consisting of filler. For instance, to
+// make a p13 admin profile, edit replaces "role=user" with "role=admin". It picks the x that takes the fewest
+// queries.
+func (c *CutAndPaste) Forge(edit func(plaintext string) string) ([]byte, error) {
+	bs := c.BlockSize
+	if bs <= 0 {
+		return nil, fmt.Errorf("bad block size %d", bs)
+	}
+	// Collect the blocks that appear in the encodings of inputs of filler alone, at every alignment.
+	natural := make(map[string]blockSource)
+	for n := 0; n < 3*bs; n++ {
+		input := c.filler(n)
+		for i, block := range c.blocks(input) {
+			if _, ok := natural[block]; !ok {
+				natural[block] = blockSource{input, i}
+			}
+		}
+	}
+
+	var best []blockSource
+	bestQueries := 0
+	for n := 0; n < 2*bs; n++ {
+		forged := pkcs7.Pad([]byte(edit(c.Encode(c.filler(n)))), bs)
+		plan, ok := c.plan(forged, natural)
+		if !ok {
+			continue
+		}
+		if queries := countInputs(plan); best == nil || queries < bestQueries {
+			best, bestQueries = plan, queries
+		}
+	}
+	if best == nil {
+		return nil, errors.New("cannot forge: some block of the edited plaintext can't be produced aligned")
+	}
+	return c.splice(best)
+}
+
+// plan finds a source for each block of forged.
+func (c *CutAndPaste) plan(forged []byte, natural map[string]blockSource) ([]blockSource, bool) {
+	bs := c.BlockSize
+	var plan []blockSource
+	for i := 0; i < len(forged); i += bs {
+		block := string(forged[i : i+bs])
+		if src, ok := natural[block]; ok {
+			plan = append(plan, src)
+			continue
+		}
+		src, ok := c.inject(block)
+		if !ok {
+			return nil, false
+		}
+		plan = append(plan, src)
+	}
+	return plan, true
+}
+
+// inject looks for an input of the form filler + block that puts block, unchanged, on a block boundary.
+func (c *CutAndPaste) inject(block string) (blockSource, bool) {
+	for n := 0; n < c.BlockSize; n++ {
+		input := c.filler(n) + block
+		for i, b := range c.blocks(input) {
+			if b == block {
+				return blockSource{input, i}, true
+			}
+		}
+	}
+	return blockSource{}, false
+}
+
+// splice queries the service for each input in plan and assembles the forged ciphertext.
+func (c *CutAndPaste) splice(plan []blockSource) ([]byte, error) {
+	bs := c.BlockSize
+	encrypted := make(map[string][]byte)
+	var result []byte
+	for _, src := range plan {
+		ciphertext, ok := encrypted[src.input]
+		if !ok {
+			var err error
+			ciphertext, err = c.Encrypt(src.input)
+			if err != nil {
+				return nil, err
+			}
+			encrypted[src.input] = ciphertext
+		}
+		if len(ciphertext) < (src.block+1)*bs {
+			return nil, fmt.Errorf("ciphertext for %q is shorter than expected; is Encode right?", src.input)
+		}
+		result = append(result, ciphertext[src.block*bs:(src.block+1)*bs]...)
+	}
+	return result, nil
+}
+
+// blocks splits the padded encoding of input into blocks.
+func (c *CutAndPaste) blocks(input string) []string {
+	padded := pkcs7.Pad([]byte(c.Encode(input)), c.BlockSize)
+	blocks := make([]string, len(padded)/c.BlockSize)
+	for i := range blocks {
+		blocks[i] = string(padded[i*c.BlockSize : (i+1)*c.BlockSize])
+	}
+	return blocks
+}
+
+func (c *CutAndPaste) filler(n int) string {
+	f := c.Filler
+	if f == 0 {
+		f = 'a'
+	}
+	return strings.Repeat(string([]byte{f}), n)
+}
+
+func countInputs(plan []blockSource) int {
+	inputs := make(map[string]bool)
+	for _, src := range plan {
+		inputs[src.input] = true
+	}
+	return len(inputs)
+}
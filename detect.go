@@ -0,0 +1,130 @@
+package matasano
+
+import (
+	"fmt"
+	"math"
+)
+
+// An ECBAnalysis is the evidence that a ciphertext was encrypted with ECB. The tell-tale sign of ECB is
+// repeated blocks (http://cryptopals.com/sets/1/challenges/8/): any other mode, or ECB with a plaintext that
+// has no repeated blocks, gives something indistinguishable from random, in which two identical blocks are
+// vanishingly unlikely.
+type ECBAnalysis struct {
+	Blocks         int // Complete blocks
+	RepeatedBlocks int // Blocks identical to an earlier block
+	// BlockEntropy is the Shannon entropy (in bits) of the distribution of blocks. It's log2(Blocks) if no
+	// blocks repeat and lower the more they do.
+	BlockEntropy float64
+	// PRandom is the probability that a random ciphertext of the same length would have as many pairs of
+	// identical blocks.
+	PRandom float64
+	// Likelihood is the estimated probability that the ciphertext is ECB. Confidence is how much evidence
+	// there is, from 0 (none) to 1, whichever way it points: a ciphertext with repeated blocks and a partial
+	// last block has a middling Likelihood but a high Confidence.
+	Likelihood float64
+	Confidence float64
+}
+
+// ECB reports whether ECB is more likely than not.
+func (a ECBAnalysis) ECB() bool { return a.Likelihood > 0.5 }
+
+// partialBlockEvidence is the evidence (in bits) against ECB from a ciphertext that isn't a whole number of
+// blocks. ECB ciphertexts always are, so it's strong, but not conclusive: something else might have been
+// appended (a MAC, say).
+const partialBlockEvidence = 16
+
+// AnalyzeECB looks for signs of ECB in an arbitrary ciphertext. It replaces the challenge 11 check (which
+// compared a few particular blocks of a chosen plaintext).
+//
+// The evidence for ECB is the information that the repeated blocks remove, Blocks*(log2(Blocks) -
+// BlockEntropy) bits (about 2 bits for a single repeat, and more the more blocks repeat), discounted by the
+// chance PRandom that the repeats are a coincidence. The evidence against is a partial last block. Likelihood
+// weighs the two (as if they were log-likelihood ratios) and Confidence grows with their sum.
+func AnalyzeECB(ciphertext []byte, blockSize int) (ECBAnalysis, error) {
+	if blockSize <= 0 {
+		return ECBAnalysis{}, fmt.Errorf("bad block size %d", blockSize)
+	}
+	a := ECBAnalysis{Blocks: len(ciphertext) / blockSize, PRandom: 1}
+	counts := make(map[string]int)
+	for i := 0; i < a.Blocks; i++ {
+		counts[string(ciphertext[i*blockSize:(i+1)*blockSize])]++
+	}
+	pairs := 0
+	for _, n := range counts {
+		a.RepeatedBlocks += n - 1
+		pairs += n * (n - 1) / 2
+		p := float64(n) / float64(a.Blocks)
+		a.BlockEntropy -= p * math.Log2(p)
+	}
+
+	var forECB, againstECB float64 // In bits
+	if pairs > 0 {
+		// The number of colliding pairs among random blocks is approximately Poisson-distributed.
+		allPairs := float64(a.Blocks) * float64(a.Blocks-1) / 2
+		a.PRandom = poissonTail(allPairs*math.Pow(2, -8*float64(blockSize)), pairs)
+		deficit := math.Max(0, math.Log2(float64(a.Blocks))-a.BlockEntropy)
+		forECB = (1 - a.PRandom) * float64(a.Blocks) * deficit
+	}
+	if len(ciphertext)%blockSize != 0 {
+		againstECB = partialBlockEvidence
+	}
+	a.Likelihood = 1 / (1 + math.Exp2(againstECB-forECB))
+	a.Confidence = 1 - math.Exp2(-(forECB + againstECB))
+	return a, nil
+}
+
+// poissonTail returns P(X >= k) for X ~ Poisson(lambda), for small lambda.
+func poissonTail(lambda float64, k int) float64 {
+	if k == 0 {
+		return 1
+	}
+	// P(X >= k) = 1 - sum_{j<k} P(X = j). For the tiny lambdas here that sum is 1 to within floating-point
+	// error, so add up the (rapidly shrinking) terms from k onwards instead.
+	term := math.Exp(-lambda)
+	for j := 1; j <= k; j++ {
+		term *= lambda / float64(j)
+	}
+	tail := 0.0
+	for j := k; term > 0 && j < k+100; j++ {
+		tail += term
+		term *= lambda / float64(j+1)
+	}
+	return math.Min(tail, 1)
+}
+
+// DetectMode uses chosen plaintexts to decide whether an oracle encrypts with ECB, CBC, or a stream mode (which
+// it reports as CTR; OFB looks the same from the outside). It works even if the oracle adds a random prefix and
+// suffix or uses a fresh key for each query, so long as it sticks to one mode. ECB takes one query; telling
+// CBC from a stream mode takes detectQueries.
+func DetectMode(oracle Oracle, blockSize int) (Mode, error) {
+	if blockSize <= 0 {
+		return 0, fmt.Errorf("bad block size %d", blockSize)
+	}
+	// Three blocks of identical bytes contain at least two complete, identical blocks, however the input is
+	// aligned. After that, the only difference between CBC and a stream mode is that CBC always produces whole
+	// blocks. A stream mode might too, by coincidence, so try again with different lengths. (If the oracle's
+	// output length depends only on its input's, two tries are enough; if it adds random padding, it takes a
+	// few more to be sure.)
+	for i := 0; i < detectQueries; i++ {
+		encrypted, err := oracle.Encrypt(make([]byte, 3*blockSize+i))
+		if err != nil {
+			return 0, err
+		}
+		if len(encrypted)%blockSize != 0 {
+			return CTR, nil
+		}
+		if i == 0 {
+			a, err := AnalyzeECB(encrypted, blockSize)
+			if err != nil {
+				return 0, err
+			}
+			if a.ECB() {
+				return ECB, nil
+			}
+		}
+	}
+	return CBC, nil
+}
+
+// detectQueries is the number of queries DetectMode makes before concluding that an oracle uses CBC.
+const detectQueries = 6
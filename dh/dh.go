@@ -0,0 +1,74 @@
+// Package dh implements Diffie-Hellman key exchange as described at
+// http://cryptopals.com/sets/5/challenges/33/.
+package dh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+)
+
+// A Group is a prime modulus and a generator.
+type Group struct {
+	P *big.Int
+	G *big.Int
+}
+
+// This is the 1536-bit MODP group from RFC 3526 (the "NIST prime" in the challenge).
+const rfc3526Prime1536 = "" +
+	"ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74" +
+	"020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f1437" +
+	"4fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7ed" +
+	"ee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf05" +
+	"98da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb" +
+	"9ed529077096966d670c354e4abc9804f1746c08ca237327ffffffffffffffff"
+
+// DefaultGroup is the RFC 3526 1536-bit group with generator 2.
+var DefaultGroup = &Group{P: matasano.MustHexToInt(rfc3526Prime1536), G: big.NewInt(2)}
+
+// A PrivateKey is a secret exponent X along with the corresponding public value Y = G^X mod P.
+type PrivateKey struct {
+	*Group
+	X *big.Int
+	Y *big.Int
+}
+
+// GenerateKey picks a random private key in [1, P) using rand (crypto/rand if nil) as the source of
+// randomness.
+func (g *Group) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	x, err := matasano.RandomNonzero(rand, g.P)
+	if err != nil {
+		return nil, err
+	}
+	return g.NewKey(x), nil
+}
+
+// NewKey creates the PrivateKey with exponent x.
+func (g *Group) NewKey(x *big.Int) *PrivateKey {
+	return &PrivateKey{
+		Group: g,
+		X:     x,
+		Y:     new(big.Int).Exp(g.G, x, g.P),
+	}
+}
+
+// SharedSecret computes peer^X mod P, where peer is the other party's public value.
+func (k *PrivateKey) SharedSecret(peer *big.Int) *big.Int {
+	return new(big.Int).Exp(peer, k.X, k.P)
+}
+
+// AESKey derives a 16-byte AES key from a shared secret: the first 16 bytes of SHA1(s).
+func AESKey(s *big.Int) []byte {
+	h := sha1.Sum(s.Bytes())
+	return h[:16]
+}
+
+// NewCipher returns an AES cipher.Block keyed with AESKey(s), suitable for passing to
+// matasano.NewCBCEncrypter and friends.
+func NewCipher(s *big.Int) (cipher.Block, error) {
+	return aes.NewCipher(AESKey(s))
+}
@@ -0,0 +1,95 @@
+package dsa
+
+import (
+	"errors"
+	"math/big"
+)
+
+// RecoverX computes the private key x from a signature of a message with hash h, given the nonce k that was
+// used: x = (s*k - h) / r mod q.
+func RecoverX(params *Parameters, h *big.Int, sig *Signature, k *big.Int) (*big.Int, error) {
+	rInv := new(big.Int).ModInverse(sig.R, params.Q)
+	if rInv == nil {
+		return nil, errors.New("r is not invertible mod q")
+	}
+	x := new(big.Int).Mul(sig.S, k)
+	x.Sub(x, h)
+	x.Mul(x, rInv)
+	return x.Mod(x, params.Q), nil
+}
+
+// checkX reports whether x is the private key corresponding to pub.
+func checkX(pub *PublicKey, x *big.Int) bool {
+	return new(big.Int).Exp(pub.G, x, pub.P).Cmp(pub.Y) == 0
+}
+
+// RecoverXFromNonceRange is the attack from http://cryptopals.com/sets/6/challenges/43/: if the nonce used to
+// make sig is known to be in [lo, hi], then just try each one and see which gives the right public key.
+func RecoverXFromNonceRange(pub *PublicKey, h *big.Int, sig *Signature, lo, hi int64) (*big.Int, error) {
+	k := new(big.Int)
+	for i := lo; i <= hi; i++ {
+		k.SetInt64(i)
+		x, err := RecoverX(pub.Parameters, h, sig, k)
+		if err != nil {
+			return nil, err
+		}
+		if checkX(pub, x) {
+			return x, nil
+		}
+	}
+	return nil, errors.New("no nonce in range gives the right key")
+}
+
+// A SignedMessage is a message hash and its signature.
+type SignedMessage struct {
+	H   *big.Int
+	Sig *Signature
+}
+
+// RecoverXFromRepeatedNonce is the attack from http://cryptopals.com/sets/6/challenges/44/. Two signatures
+// made with the same nonce have the same r (since r only depends on k), and then
+// k = (h1 - h2) / (s1 - s2) mod q. It returns the private key and the indexes of the two messages that gave
+// it away.
+func RecoverXFromRepeatedNonce(pub *PublicKey, msgs []SignedMessage) (x *big.Int, i, j int, err error) {
+	byR := make(map[string]int)
+	for cur, m := range msgs {
+		prev, ok := byR[m.Sig.R.String()]
+		if !ok {
+			byR[m.Sig.R.String()] = cur
+			continue
+		}
+		a, b := msgs[prev], m
+		ds := new(big.Int).Sub(a.Sig.S, b.Sig.S)
+		ds.Mod(ds, pub.Q)
+		dsInv := new(big.Int).ModInverse(ds, pub.Q)
+		if dsInv == nil {
+			continue
+		}
+		k := new(big.Int).Sub(a.H, b.H)
+		k.Mul(k, dsInv)
+		k.Mod(k, pub.Q)
+		key, err := RecoverX(pub.Parameters, a.H, a.Sig, k)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if checkX(pub, key) {
+			return key, prev, cur, nil
+		}
+	}
+	return nil, 0, 0, errors.New("no repeated nonce found")
+}
+
+// MagicSignature is the forgery from http://cryptopals.com/sets/6/challenges/45/ for when g = p + 1 (so that
+// g^anything = 1 mod p). For any z, r = (y^z mod p) mod q and s = r / z mod q is a valid signature for every
+// message.
+func MagicSignature(pub *PublicKey, z *big.Int) (*Signature, error) {
+	zInv := new(big.Int).ModInverse(z, pub.Q)
+	if zInv == nil {
+		return nil, errors.New("z is not invertible mod q")
+	}
+	r := new(big.Int).Exp(pub.Y, z, pub.P)
+	r.Mod(r, pub.Q)
+	s := new(big.Int).Mul(r, zInv)
+	s.Mod(s, pub.Q)
+	return &Signature{R: r, S: s}, nil
+}
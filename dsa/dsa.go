@@ -0,0 +1,145 @@
+// Package dsa implements DSA as described at http://cryptopals.com/sets/6/challenges/43/, along with the
+// nonce-recovery and parameter-tampering attacks from /43/ through /45/.
+package dsa
+
+import (
+	"crypto/sha1"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+)
+
+var one = big.NewInt(1)
+
+// Parameters are the domain parameters shared by a set of keys.
+type Parameters struct {
+	P *big.Int
+	Q *big.Int
+	G *big.Int
+}
+
+// DefaultParameters are the parameters given in the challenge.
+var DefaultParameters = &Parameters{
+	P: matasano.MustHexToInt("800000000000000089e1855218a0e7dac38136ffafa72eda7" +
+		"859f2171e25e65eac698c1702578b07dc2a1076da241c76c6" +
+		"2d374d8389ea5aeffd3226a0530cc565f3bf6b50929139ebe" +
+		"ac04f48c3c84afb796d61e5a4f9a8fda812ab59494232c7d2" +
+		"b4deb50aa18ee9e132bfa85ac4374d7f9091abc3d015efc87" +
+		"1a584471bb1"),
+	Q: matasano.MustHexToInt("f4f47f05794b256174bba6e9b396a7707e563c5b"),
+	G: matasano.MustHexToInt("5958c9d3898b224b12672c0b98e06c60df923cb8bc999d119" +
+		"458fef538b8fa4046c8db53039db620c094c9fa077ef389b5" +
+		"322a559946a71903f990f1f7e0e025e2d7f7cf494aff1a047" +
+		"0f5b64c36b625a097f1651fe775323556fe00b3608c887892" +
+		"878480e99041be601a62166ca6894bdd41a7054ec89f756ba" +
+		"9fc95302291"),
+}
+
+type PublicKey struct {
+	*Parameters
+	Y *big.Int
+}
+
+type PrivateKey struct {
+	PublicKey
+	X *big.Int
+}
+
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// GenerateKey picks a random private key x in [1, q) using rand (crypto/rand if nil).
+func (params *Parameters) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	x, err := matasano.RandomNonzero(rand, params.Q)
+	if err != nil {
+		return nil, err
+	}
+	return params.NewKey(x), nil
+}
+
+// NewKey creates the PrivateKey with secret x.
+func (params *Parameters) NewKey(x *big.Int) *PrivateKey {
+	return &PrivateKey{
+		PublicKey: PublicKey{
+			Parameters: params,
+			Y:          new(big.Int).Exp(params.G, x, params.P),
+		},
+		X: x,
+	}
+}
+
+// Hash is the message hash used for signing: SHA-1 interpreted as an integer.
+func Hash(msg []byte) *big.Int {
+	h := sha1.Sum(msg)
+	return new(big.Int).SetBytes(h[:])
+}
+
+// Sign signs msg with a random nonce read from rand (crypto/rand if nil).
+func (k *PrivateKey) Sign(rand io.Reader, msg []byte) (*Signature, error) {
+	for {
+		nonce, err := matasano.RandomNonzero(rand, k.Q)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := k.SignWithNonce(msg, nonce)
+		if err != nil {
+			return nil, err
+		}
+		// The standard says to pick a new k if r or s is zero -- unless someone has messed with g, this is
+		// astronomically unlikely. (If someone has messed with g, we'll go ahead anyway; see VerifyLoose.)
+		if sig.S.Sign() != 0 {
+			return sig, nil
+		}
+	}
+}
+
+// SignWithNonce signs msg using the given nonce k. This is only for demonstrating attacks; reusing or
+// leaking k gives away the private key.
+func (k *PrivateKey) SignWithNonce(msg []byte, nonce *big.Int) (*Signature, error) {
+	kInv := new(big.Int).ModInverse(nonce, k.Q)
+	if kInv == nil {
+		return nil, errors.New("nonce is not invertible mod q")
+	}
+	r := new(big.Int).Exp(k.G, nonce, k.P)
+	r.Mod(r, k.Q)
+	s := new(big.Int).Mul(k.X, r)
+	s.Add(s, Hash(msg))
+	s.Mul(s, kInv)
+	s.Mod(s, k.Q)
+	return &Signature{R: r, S: s}, nil
+}
+
+// Verify checks sig against msg, including checking that 0 < r < q and 0 < s < q.
+func (k *PublicKey) Verify(msg []byte, sig *Signature) bool {
+	if !inRange(sig.R, k.Q) || !inRange(sig.S, k.Q) {
+		return false
+	}
+	return k.VerifyLoose(msg, sig)
+}
+
+// VerifyLoose checks sig against msg without checking that r and s are in range. With g = 0, this accepts
+// any signature with r = 0.
+func (k *PublicKey) VerifyLoose(msg []byte, sig *Signature) bool {
+	w := new(big.Int).ModInverse(sig.S, k.Q)
+	if w == nil {
+		return false
+	}
+	u1 := new(big.Int).Mul(Hash(msg), w)
+	u1.Mod(u1, k.Q)
+	u2 := new(big.Int).Mul(sig.R, w)
+	u2.Mod(u2, k.Q)
+	v := new(big.Int).Exp(k.G, u1, k.P)
+	v.Mul(v, new(big.Int).Exp(k.Y, u2, k.P))
+	v.Mod(v, k.P)
+	v.Mod(v, k.Q)
+	return v.Cmp(sig.R) == 0
+}
+
+// inRange reports whether 0 < n < max.
+func inRange(n, max *big.Int) bool {
+	return n.Sign() > 0 && n.Cmp(max) < 0
+}
@@ -0,0 +1,84 @@
+package gcm
+
+import (
+	"errors"
+	"io"
+)
+
+// A Message is a GCM ciphertext with its tag and additional data.
+type Message struct {
+	AdditionalData []byte
+	Ciphertext     []byte // Without the tag
+	Tag            []byte
+}
+
+// SplitSealed splits the output of Seal into a Message. It returns an error if sealed is too short to hold a
+// tag.
+func SplitSealed(additionalData, sealed []byte) (Message, error) {
+	if len(sealed) < TagSize {
+		return Message{}, errors.New("gcm: sealed message shorter than a tag")
+	}
+	n := len(sealed) - TagSize
+	return Message{AdditionalData: additionalData, Ciphertext: sealed[:n], Tag: sealed[n:]}, nil
+}
+
+// tagPoly returns GHASH(y, A, C) + T as a polynomial in y. Evaluated at the authentication key H, it's the
+// mask E(K, J0), which depends only on the key and nonce.
+func tagPoly(m Message) Poly {
+	blocks := ghashBlocks(m.AdditionalData, m.Ciphertext)
+	p := make(Poly, len(blocks)+1)
+	for j, b := range blocks {
+		p[len(blocks)-j] = b
+	}
+	p[0] = ElementFromBytes(m.Tag)
+	return p
+}
+
+// RecoverAuthKey returns the candidates for the authentication key H given at least two messages sealed
+// with the same key and nonce. For any two such messages, H is a root of the difference of their tagPolys,
+// since the masks cancel. The more messages there are, the fewer candidates survive; with three, there's
+// usually just one.
+func RecoverAuthKey(msgs []Message, rand io.Reader) ([]Element, error) {
+	if len(msgs) < 2 {
+		return nil, errors.New("gcm: need at least two messages with the same nonce")
+	}
+	first := tagPoly(msgs[0])
+	diff := first.Add(tagPoly(msgs[1]))
+	if diff.Degree() < 1 {
+		return nil, errors.New("gcm: first two messages are identical")
+	}
+	var candidates []Element
+	for _, h := range diff.Roots(rand) {
+		mask := first.Eval(h)
+		ok := true
+		for _, m := range msgs[2:] {
+			if tagPoly(m).Eval(h) != mask {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			candidates = append(candidates, h)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("gcm: no candidates; were the messages really sealed with the same nonce?")
+	}
+	return candidates, nil
+}
+
+// A Forger makes valid tags for arbitrary messages under a key and nonce whose authentication key is known.
+type Forger struct {
+	h    Element
+	mask Element // E(K, J0)
+}
+
+// NewForger returns a Forger for the key and nonce that m was sealed with, given its authentication key h.
+func NewForger(h Element, m Message) *Forger {
+	return &Forger{h: h, mask: tagPoly(m).Eval(h)}
+}
+
+// Tag returns the tag for ciphertext and additionalData.
+func (f *Forger) Tag(additionalData, ciphertext []byte) []byte {
+	return GHASH(f.h, additionalData, ciphertext).Add(f.mask).Bytes()
+}
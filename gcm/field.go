@@ -0,0 +1,105 @@
+package gcm
+
+import "encoding/binary"
+
+// An Element is an element of GF(2^128) = GF(2)[x]/(x^128 + x^7 + x^2 + x + 1). Internally, bit i of hi:lo
+// is the coefficient of x^i. (GCM's byte encoding is the other way around: the most significant bit of the
+// first byte is the coefficient of x^0. See ElementFromBytes.)
+type Element struct {
+	hi, lo uint64
+}
+
+var (
+	zero = Element{}
+	one  = Element{lo: 1}
+)
+
+// ElementFromBytes decodes a 16-byte block as GCM does.
+func ElementFromBytes(b []byte) Element {
+	_ = b[15]
+	return Element{
+		hi: reverse64(binary.BigEndian.Uint64(b[8:])),
+		lo: reverse64(binary.BigEndian.Uint64(b[:8])),
+	}
+}
+
+// Bytes encodes e as a 16-byte block, as GCM does.
+func (e Element) Bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], reverse64(e.lo))
+	binary.BigEndian.PutUint64(b[8:], reverse64(e.hi))
+	return b
+}
+
+func reverse64(v uint64) uint64 {
+	var r uint64
+	for i := 0; i < 64; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+func (e Element) IsZero() bool { return e == zero }
+
+// Add returns e + f (which is also e - f).
+func (e Element) Add(f Element) Element {
+	return Element{e.hi ^ f.hi, e.lo ^ f.lo}
+}
+
+// Mul returns e * f.
+func (e Element) Mul(f Element) Element {
+	var r Element
+	a := e
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = f.lo >> uint(i) & 1
+		} else {
+			bit = f.hi >> uint(i-64) & 1
+		}
+		if bit == 1 {
+			r = r.Add(a)
+		}
+		a = a.mulX()
+	}
+	return r
+}
+
+// mulX returns e * x.
+func (e Element) mulX() Element {
+	overflow := e.hi >> 63
+	r := Element{hi: e.hi<<1 | e.lo>>63, lo: e.lo << 1}
+	if overflow == 1 {
+		// x^128 = x^7 + x^2 + x + 1
+		r.lo ^= 0x87
+	}
+	return r
+}
+
+// Pow returns e^n, where n is given as a big-endian byte string.
+func (e Element) Pow(n []byte) Element {
+	r := one
+	for _, b := range n {
+		for i := 7; i >= 0; i-- {
+			r = r.Mul(r)
+			if b>>uint(i)&1 == 1 {
+				r = r.Mul(e)
+			}
+		}
+	}
+	return r
+}
+
+// Inv returns 1/e, which is e^(2^128 - 2). It panics if e is zero.
+func (e Element) Inv() Element {
+	if e.IsZero() {
+		panic("gcm: inverse of zero")
+	}
+	n := make([]byte, 16)
+	for i := range n {
+		n[i] = 0xff
+	}
+	n[15] = 0xfe
+	return e.Pow(n)
+}
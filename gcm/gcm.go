@@ -0,0 +1,136 @@
+// Package gcm implements Galois/Counter Mode from scratch (as specified in NIST SP 800-38D) and the "forbidden
+// attack" on it described at http://cryptopals.com/sets/8/challenges/63/: if a nonce is ever reused, the
+// authentication key can be recovered and then tags can be forged.
+package gcm
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	blockSize = 16
+	NonceSize = 12
+	TagSize   = 16
+)
+
+type gcm struct {
+	b cipher.Block
+	h Element // The authentication key, E(K, 0^128)
+}
+
+// New returns GCM with 96-bit nonces and 128-bit tags over a block cipher with a 128-bit block size.
+func New(b cipher.Block) (cipher.AEAD, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errors.New("gcm: cipher must have a 128-bit block size")
+	}
+	h := make([]byte, blockSize)
+	b.Encrypt(h, h)
+	return &gcm{b: b, h: ElementFromBytes(h)}, nil
+}
+
+func (g *gcm) NonceSize() int { return NonceSize }
+func (g *gcm) Overhead() int  { return TagSize }
+
+func (g *gcm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic("gcm: incorrect nonce length")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+TagSize)
+	j0 := counterBlock(nonce)
+	g.ctr(out[:len(plaintext)], plaintext, j0)
+	copy(out[len(plaintext):], g.tag(j0, additionalData, out[:len(plaintext)]))
+	return ret
+}
+
+var errOpen = errors.New("gcm: message authentication failed")
+
+func (g *gcm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic("gcm: incorrect nonce length")
+	}
+	if len(ciphertext) < TagSize {
+		return nil, errOpen
+	}
+	tag := ciphertext[len(ciphertext)-TagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-TagSize]
+	j0 := counterBlock(nonce)
+	if subtle.ConstantTimeCompare(tag, g.tag(j0, additionalData, ciphertext)) != 1 {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	g.ctr(out, ciphertext, j0)
+	return ret, nil
+}
+
+// counterBlock returns J0, the initial counter block for a 96-bit nonce.
+func counterBlock(nonce []byte) []byte {
+	j0 := make([]byte, blockSize)
+	copy(j0, nonce)
+	j0[blockSize-1] = 1
+	return j0
+}
+
+// ctr encrypts src into dst with counter mode starting at inc32(j0), where the counter is the last 32 bits
+// of the block.
+func (g *gcm) ctr(dst, src, j0 []byte) {
+	counter := append([]byte{}, j0...)
+	stream := make([]byte, blockSize)
+	for i := 0; i < len(src); i += blockSize {
+		binary.BigEndian.PutUint32(counter[12:], binary.BigEndian.Uint32(counter[12:])+1)
+		g.b.Encrypt(stream, counter)
+		for j := i; j < len(src) && j < i+blockSize; j++ {
+			dst[j] = src[j] ^ stream[j-i]
+		}
+	}
+}
+
+// tag computes the authentication tag, GHASH(H, A, C) + E(K, J0).
+func (g *gcm) tag(j0, additionalData, ciphertext []byte) []byte {
+	s := make([]byte, blockSize)
+	g.b.Encrypt(s, j0)
+	return GHASH(g.h, additionalData, ciphertext).Add(ElementFromBytes(s)).Bytes()
+}
+
+// GHASH computes the GCM hash of additionalData and ciphertext with key h. It's the polynomial (in h)
+// whose coefficients are the blocks of additionalData, then ciphertext (each zero-padded to a whole number
+// of blocks), then a block with their lengths in bits, evaluated at h:
+//
+//	B_1*h^m + B_2*h^(m-1) + ... + B_m*h
+func GHASH(h Element, additionalData, ciphertext []byte) Element {
+	var y Element
+	for _, b := range ghashBlocks(additionalData, ciphertext) {
+		y = y.Add(b).Mul(h)
+	}
+	return y
+}
+
+// ghashBlocks returns the blocks that GHASH hashes, B_1 through B_m.
+func ghashBlocks(additionalData, ciphertext []byte) []Element {
+	var blocks []Element
+	for _, data := range [][]byte{additionalData, ciphertext} {
+		for i := 0; i < len(data); i += blockSize {
+			block := make([]byte, blockSize)
+			copy(block, data[i:])
+			blocks = append(blocks, ElementFromBytes(block))
+		}
+	}
+	lengths := make([]byte, blockSize)
+	binary.BigEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.BigEndian.PutUint64(lengths[8:], uint64(len(ciphertext))*8)
+	return append(blocks, ElementFromBytes(lengths))
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the new part.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return head, tail
+}
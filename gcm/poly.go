@@ -0,0 +1,159 @@
+package gcm
+
+import (
+	"io"
+
+	"github.com/cespare/matasano"
+)
+
+// A Poly is a polynomial over GF(2^128); p[i] is the coefficient of y^i. Polys returned by the functions
+// here are normalized: the leading coefficient is non-zero (so the zero polynomial is empty).
+type Poly []Element
+
+func (p Poly) normalize() Poly {
+	for len(p) > 0 && p[len(p)-1].IsZero() {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// Degree returns the degree of p, or -1 for the zero polynomial.
+func (p Poly) Degree() int { return len(p.normalize()) - 1 }
+
+func (p Poly) Add(q Poly) Poly {
+	if len(p) < len(q) {
+		p, q = q, p
+	}
+	r := append(Poly{}, p...)
+	for i, c := range q {
+		r[i] = r[i].Add(c)
+	}
+	return r.normalize()
+}
+
+func (p Poly) Mul(q Poly) Poly {
+	p, q = p.normalize(), q.normalize()
+	if len(p) == 0 || len(q) == 0 {
+		return nil
+	}
+	r := make(Poly, len(p)+len(q)-1)
+	for i, a := range p {
+		for j, b := range q {
+			r[i+j] = r[i+j].Add(a.Mul(b))
+		}
+	}
+	return r.normalize()
+}
+
+// Eval returns p(x).
+func (p Poly) Eval(x Element) Element {
+	var r Element
+	for i := len(p) - 1; i >= 0; i-- {
+		r = r.Mul(x).Add(p[i])
+	}
+	return r
+}
+
+// DivMod returns the quotient and remainder of p / q. It panics if q is zero.
+func (p Poly) DivMod(q Poly) (quo, rem Poly) {
+	q = q.normalize()
+	if len(q) == 0 {
+		panic("gcm: polynomial division by zero")
+	}
+	rem = append(Poly{}, p.normalize()...)
+	if len(rem) < len(q) {
+		return nil, rem
+	}
+	quo = make(Poly, len(rem)-len(q)+1)
+	inv := q[len(q)-1].Inv()
+	for len(rem) >= len(q) {
+		shift := len(rem) - len(q)
+		c := rem[len(rem)-1].Mul(inv)
+		quo[shift] = c
+		for i, b := range q {
+			rem[shift+i] = rem[shift+i].Add(c.Mul(b))
+		}
+		rem = rem.normalize()
+	}
+	return quo.normalize(), rem
+}
+
+func (p Poly) Mod(q Poly) Poly {
+	_, rem := p.DivMod(q)
+	return rem
+}
+
+// Monic returns p divided by its leading coefficient.
+func (p Poly) Monic() Poly {
+	p = p.normalize()
+	if len(p) == 0 {
+		return nil
+	}
+	inv := p[len(p)-1].Inv()
+	r := make(Poly, len(p))
+	for i, c := range p {
+		r[i] = c.Mul(inv)
+	}
+	return r
+}
+
+// GCD returns the monic greatest common divisor of p and q.
+func GCD(p, q Poly) Poly {
+	p, q = p.normalize(), q.normalize()
+	for len(q) > 0 {
+		p, q = q, p.Mod(q)
+	}
+	return p.Monic()
+}
+
+// squareMod returns p^2 mod m.
+func (p Poly) squareMod(m Poly) Poly { return p.Mul(p).Mod(m) }
+
+// Roots returns the distinct roots of p in GF(2^128), in no particular order. It uses rand (crypto/rand if
+// nil) to split p into factors.
+func (p Poly) Roots(rand io.Reader) []Element {
+	rand = matasano.RandOrDefault(rand)
+	f := p.Monic()
+	if len(f) == 0 {
+		panic("gcm: roots of the zero polynomial")
+	}
+	// Every element of the field is a root of y^(2^128) - y, so gcd(f, y^(2^128) - y) is the product of the
+	// distinct linear factors of f.
+	y := Poly{zero, one}
+	z := y.Mod(f)
+	for i := 0; i < 128; i++ {
+		z = z.squareMod(f)
+	}
+	return splitLinear(GCD(f, z.Add(y)), rand)
+}
+
+// splitLinear returns the roots of f, which is monic and a product of distinct linear factors. It uses the
+// Cantor-Zassenhaus algorithm, adapted to characteristic 2 by using the trace map instead of the usual
+// (q-1)/2 power: for random a, Tr(a*y) = sum_i (a*y)^(2^i) is 0 at about half the roots of f and 1 at the
+// others, so gcd(f, Tr(a*y) mod f) is likely to be a proper factor.
+func splitLinear(f Poly, rand io.Reader) []Element {
+	switch f.Degree() {
+	case -1, 0:
+		return nil
+	case 1:
+		// y + c has root c.
+		return []Element{f[0]}
+	}
+	for {
+		var b [16]byte
+		if _, err := io.ReadFull(rand, b[:]); err != nil {
+			panic(err)
+		}
+		t := Poly{zero, ElementFromBytes(b[:])}.Mod(f)
+		tr := t
+		for i := 1; i < 128; i++ {
+			t = t.squareMod(f)
+			tr = tr.Add(t)
+		}
+		d := GCD(f, tr)
+		if deg := d.Degree(); deg > 0 && deg < f.Degree() {
+			quo, _ := f.DivMod(d)
+			return append(splitLinear(d, rand), splitLinear(quo, rand)...)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package matasano
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The wrappers in this file add instrumentation and misbehavior to an Oracle. They can be nested to combine
+// them; for example,
+//
+//	NewFlakyOracle(NewBudgetOracle(NewCountingOracle(oracle), 5000), 0.01)
+//
+// counts the queries that get through, allows at most 5000 of them, and fails 1% of queries before they reach
+// the budget. All of them are safe for concurrent use if the wrapped Oracle is.
+
+// CountingOracle wraps an Oracle and counts the queries made to it. It's for measuring how economical an
+// attack is.
+type CountingOracle struct {
+	Oracle
+	calls     int64
+	bytesSent int64
+}
+
+func NewCountingOracle(oracle Oracle) *CountingOracle {
+	return &CountingOracle{Oracle: oracle}
+}
+
+func (o *CountingOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	atomic.AddInt64(&o.calls, 1)
+	atomic.AddInt64(&o.bytesSent, int64(len(plaintext)))
+	return o.Oracle.Encrypt(plaintext)
+}
+
+// Calls returns the number of times Encrypt has been called.
+func (o *CountingOracle) Calls() int64 { return atomic.LoadInt64(&o.calls) }
+
+// BytesSent returns the total length of the plaintexts passed to Encrypt.
+func (o *CountingOracle) BytesSent() int64 { return atomic.LoadInt64(&o.bytesSent) }
+
+// LoggingOracle wraps an Oracle and writes a line to W for each query, giving the query number, the input,
+// and the output or error (in hex).
+type LoggingOracle struct {
+	Oracle
+	W io.Writer
+
+	mu sync.Mutex
+	n  int
+}
+
+func NewLoggingOracle(oracle Oracle, w io.Writer) *LoggingOracle {
+	return &LoggingOracle{Oracle: oracle, W: w}
+}
+
+func (o *LoggingOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	encrypted, err := o.Oracle.Encrypt(plaintext)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.n++
+	if err != nil {
+		fmt.Fprintf(o.W, "%d: %x -> error: %s\n", o.n, plaintext, err)
+	} else {
+		fmt.Fprintf(o.W, "%d: %x -> %x\n", o.n, plaintext, encrypted)
+	}
+	return encrypted, err
+}
+
+var ErrBudgetExhausted = errors.New("oracle query budget exhausted")
+
+// BudgetOracle wraps an Oracle and allows only a fixed number of queries. After that, Encrypt returns
+// ErrBudgetExhausted.
+type BudgetOracle struct {
+	Oracle
+	remaining int64
+}
+
+func NewBudgetOracle(oracle Oracle, budget int64) *BudgetOracle {
+	return &BudgetOracle{Oracle: oracle, remaining: budget}
+}
+
+func (o *BudgetOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	if atomic.AddInt64(&o.remaining, -1) < 0 {
+		return nil, ErrBudgetExhausted
+	}
+	return o.Oracle.Encrypt(plaintext)
+}
+
+// Remaining returns the number of queries left in the budget.
+func (o *BudgetOracle) Remaining() int64 {
+	if n := atomic.LoadInt64(&o.remaining); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// LatencyOracle wraps an Oracle and sleeps before each query for Delay plus a random duration up to Jitter.
+type LatencyOracle struct {
+	Oracle
+	Delay  time.Duration
+	Jitter time.Duration
+	Rand   io.Reader // Source for the jitter (crypto/rand if nil)
+}
+
+func NewLatencyOracle(oracle Oracle, delay, jitter time.Duration) *LatencyOracle {
+	return &LatencyOracle{Oracle: oracle, Delay: delay, Jitter: jitter}
+}
+
+func (o *LatencyOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	d := o.Delay
+	if o.Jitter > 0 {
+		d += time.Duration(RandomIntn(o.Rand, int(o.Jitter)))
+	}
+	time.Sleep(d)
+	return o.Oracle.Encrypt(plaintext)
+}
+
+var ErrInjectedFailure = errors.New("injected oracle failure")
+
+// FlakyOracle wraps an Oracle and fails a random fraction Rate of the queries with ErrInjectedFailure
+// (without passing them on).
+type FlakyOracle struct {
+	Oracle
+	Rate float64
+	Rand io.Reader // Source for choosing which queries fail (crypto/rand if nil)
+}
+
+func NewFlakyOracle(oracle Oracle, rate float64) *FlakyOracle {
+	return &FlakyOracle{Oracle: oracle, Rate: rate}
+}
+
+func (o *FlakyOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	if RandomFloat(o.Rand) < o.Rate {
+		return nil, ErrInjectedFailure
+	}
+	return o.Oracle.Encrypt(plaintext)
+}
+
+// RetryingOracle wraps an Oracle and retries failed queries, up to Attempts tries in all. It doesn't retry
+// ErrBudgetExhausted (or an error wrapping it), since that won't go away. This makes an attack robust to a
+// FlakyOracle (or a flaky network).
+type RetryingOracle struct {
+	Oracle
+	Attempts int
+}
+
+func NewRetryingOracle(oracle Oracle, attempts int) *RetryingOracle {
+	return &RetryingOracle{Oracle: oracle, Attempts: attempts}
+}
+
+func (o *RetryingOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	var err error
+	for i := 0; i < o.Attempts || i == 0; i++ {
+		var encrypted []byte
+		encrypted, err = o.Oracle.Encrypt(plaintext)
+		if err == nil || errors.Is(err, ErrBudgetExhausted) {
+			return encrypted, err
+		}
+	}
+	return nil, err
+}
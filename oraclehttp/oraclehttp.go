@@ -0,0 +1,103 @@
+// Package oraclehttp serves a matasano.Oracle over HTTP and provides a client that implements
+// matasano.Oracle, so that attacks can be run against a "remote" target.
+//
+// The protocol is minimal: the client POSTs the raw plaintext as the request body and gets back the raw
+// ciphertext with a 200, or an error message with some other status. A 429 means that the oracle's query
+// budget is exhausted, which the client reports as an error wrapping matasano.ErrBudgetExhausted.
+package oraclehttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cespare/matasano"
+)
+
+// maxBody is the largest request the handler will accept.
+const maxBody = 1 << 20
+
+type handler struct {
+	oracle matasano.Oracle
+}
+
+// Handler returns an http.Handler that answers POSTed queries using oracle.
+func Handler(oracle matasano.Oracle) http.Handler {
+	return handler{oracle}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plaintext, ok := ReadQuery(w, r)
+	if !ok {
+		return
+	}
+	encrypted, err := h.oracle.Encrypt(plaintext)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, matasano.ErrBudgetExhausted) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(encrypted)
+}
+
+// ReadQuery reads the plaintext from a query. If the request is bad, it writes an error response and returns
+// false. (It's for handlers that need to do something more than a plain Oracle.)
+func ReadQuery(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	plaintext, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(plaintext) > maxBody {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return plaintext, true
+}
+
+// Client is an Oracle that sends its queries to a Handler at URL.
+type Client struct {
+	URL string
+	// HTTPClient is the client used to make requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+func NewClient(url string) *Client {
+	return &Client{URL: url}
+}
+
+func (c *Client) Encrypt(plaintext []byte) ([]byte, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(c.URL, "application/octet-stream", bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("oracle at %s: %w", c.URL, matasano.ErrBudgetExhausted)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oracle at %s returned %s: %s", c.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
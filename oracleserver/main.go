@@ -0,0 +1,99 @@
+// Command oracleserver serves the set 2 oracles over HTTP (see package oraclehttp) so that the attacks can be
+// practiced against a network service. Each endpoint takes the plaintext (or email address, or ciphertext) as
+// the body of a POST:
+//
+//	/11              AESOracle; the Oracle-Mode response header says whether it used ECB or CBC
+//	/12              AESOracle2 with the secret from problem 12
+//	/14              AESOracle3 with the same secret
+//	/13/profile      ProfileService.EncryptedProfileFor the email address
+//	/13/decrypt      ProfileService.DecryptProfile; responds with the decoded profile
+//
+// Keys are chosen at startup, so they're stable until the server restarts. With -seed, they (and everything
+// else the oracles choose at random) are the same every time. With -budget, /12 and /14 each answer only that
+// many queries; after that they respond with 429 Too Many Requests.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/oraclehttp"
+	"github.com/cespare/matasano/p13"
+)
+
+// This is the unknown string from problem 12.
+const secretBase64 = `
+Um9sbGluJyBpbiBteSA1LjAKV2l0aCBteSByYWctdG9wIGRvd24gc28gbXkg
+aGFpciBjYW4gYmxvdwpUaGUgZ2lybGllcyBvbiBzdGFuZGJ5IHdhdmluZyBq
+dXN0IHRvIHNheSBoaQpEaWQgeW91IHN0b3A/IE5vLCBJIGp1c3QgZHJvdmUg
+YnkK
+`
+
+func main() {
+	var (
+		addr   = flag.String("addr", "localhost:8011", "Address to listen on")
+		seed   = flag.Int64("seed", 0, "Seed for the oracles' randomness (if 0, use crypto/rand)")
+		budget = flag.Int64("budget", 0, "Limit /12 and /14 to this many queries each (if 0, no limit)")
+	)
+	flag.Parse()
+	var rand io.Reader
+	if *seed != 0 {
+		rand = matasano.SeededRand(*seed)
+	}
+
+	secret, err := matasano.Base64ToBytes(secretBase64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	profiles, err := p13.NewProfileService(rand, matasano.ECB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux.Handle("/11", aesOracleHandler{matasano.NewAESOracle(rand)})
+	limit := func(oracle matasano.Oracle) matasano.Oracle {
+		if *budget > 0 {
+			return matasano.NewBudgetOracle(oracle, *budget)
+		}
+		return oracle
+	}
+	mux.Handle("/12", oraclehttp.Handler(limit(matasano.NewAESOracle2(rand, secret))))
+	mux.Handle("/14", oraclehttp.Handler(limit(matasano.NewAESOracle3(rand, secret))))
+	mux.Handle("/13/profile", oraclehttp.Handler(matasano.OracleFunc(func(email []byte) ([]byte, error) {
+		return profiles.EncryptedProfileFor(string(email))
+	})))
+	mux.Handle("/13/decrypt", oraclehttp.Handler(matasano.OracleFunc(func(encrypted []byte) ([]byte, error) {
+		profile, err := profiles.DecryptProfile(encrypted)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(profile.Encode()), nil
+	})))
+
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// aesOracleHandler is like an oraclehttp.Handler for AESOracle, but it also tells the client which mode it
+// used so that it can check its guess.
+type aesOracleHandler struct {
+	oracle *matasano.AESOracle
+}
+
+func (h aesOracleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plaintext, ok := oraclehttp.ReadQuery(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.oracle.Query(plaintext)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Oracle-Mode", res.Mode.String())
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(res.Ciphertext)
+}
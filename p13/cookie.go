@@ -0,0 +1,95 @@
+package p13
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Cookie is an ordered list of key-value pairs, encoded like "foo=bar&baz=qux&zap=zazzle" as described
+// at http://cryptopals.com/sets/2/challenges/13/.
+type Cookie []Pair
+
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// metachars are stripped from keys and values when encoding, so that nobody can inject their own pairs.
+var metachars = strings.NewReplacer("&", "", "=", "")
+
+// Encode encodes c, eating any '&' and '=' characters in the keys and values. It doesn't escape anything
+// else (escaping the low bytes that show up in padding would spoil the attack).
+func (c Cookie) Encode() string {
+	parts := make([]string, len(c))
+	for i, p := range c {
+		parts[i] = metachars.Replace(p.Key) + "=" + metachars.Replace(p.Value)
+	}
+	return strings.Join(parts, "&")
+}
+
+// ParseCookie parses an encoded cookie. Every pair must have a key and an '='.
+func ParseCookie(s string) (Cookie, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var c Cookie
+	for _, part := range strings.Split(s, "&") {
+		i := strings.IndexByte(part, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("bad cookie pair %q", part)
+		}
+		c = append(c, Pair{Key: part[:i], Value: part[i+1:]})
+	}
+	return c, nil
+}
+
+// Get returns the value for key. If key appears more than once, the last one wins (as when the cookie is
+// parsed into an object in the challenge).
+func (c Cookie) Get(key string) (string, bool) {
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].Key == key {
+			return c[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// A Profile is a user as stored in a cookie.
+type Profile struct {
+	Email string
+	UID   int
+	Role  string
+}
+
+func (p *Profile) Cookie() Cookie {
+	return Cookie{
+		{"email", p.Email},
+		{"uid", strconv.Itoa(p.UID)},
+		{"role", p.Role},
+	}
+}
+
+func (p *Profile) Encode() string { return p.Cookie().Encode() }
+
+var errBadProfile = errors.New("cookie is not a valid profile")
+
+// ParseProfile decodes an encoded profile. The email, uid, and role must all be present.
+func ParseProfile(s string) (*Profile, error) {
+	c, err := ParseCookie(s)
+	if err != nil {
+		return nil, err
+	}
+	email, ok1 := c.Get("email")
+	uid, ok2 := c.Get("uid")
+	role, ok3 := c.Get("role")
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errBadProfile
+	}
+	p := &Profile{Email: email, Role: role}
+	if p.UID, err = strconv.Atoi(uid); err != nil {
+		return nil, errBadProfile
+	}
+	return p, nil
+}
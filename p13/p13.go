@@ -1,51 +1,150 @@
+// Package p13 implements the profile service described at http://cryptopals.com/sets/2/challenges/13/.
 package p13
 
 import (
 	"crypto/aes"
+	"crypto/cipher"
+	"errors"
 	"fmt"
-	"net/url"
-	"strings"
+	"io"
+	"sync"
 
 	"github.com/cespare/matasano"
 	"github.com/cespare/matasano/pkcs7"
 )
 
-// Just using net/url for query parsing.
+// DefaultUID is the uid in the profile of a user who hasn't registered (as in the challenge).
+const DefaultUID = 10
 
+// ProfileFor encodes the profile of a new, unregistered user with the given email address.
 func ProfileFor(email string) string {
-	// Using url.Values{...}.Encode() is a bit too robust for my attack to work (low bytes like 0x1 are
-	// escaped).
-	escapedEmail := strings.NewReplacer("&", "", "=", "").Replace(email)
-	return fmt.Sprintf("email=%s&uid=10&role=user", escapedEmail)
+	return (&Profile{Email: email, UID: DefaultUID, Role: "user"}).Encode()
 }
 
-var key []byte
+// ProfileService hands out encrypted profiles. Each service has its own key, and encrypts with its own mode:
+// ECB as in the challenge, or CBC, CTR, or OFB (in which case the ciphertext starts with a random IV). It
+// also keeps a store of registered users, who get incrementing uids.
+type ProfileService struct {
+	block cipher.Block
+	mode  matasano.Mode
+	rand  io.Reader
 
-func init() { key = matasano.RandomSlice(16) }
+	mu      sync.Mutex
+	users   map[string]*Profile
+	nextUID int
+}
 
-func EncryptedProfileFor(email string) ([]byte, error) {
-	plaintext := ProfileFor(email)
-	cipher, err := aes.NewCipher(key)
+// NewProfileService creates a service that encrypts with mode, using rand (crypto/rand if nil) for its key
+// and IVs.
+func NewProfileService(rand io.Reader, mode matasano.Mode) (*ProfileService, error) {
+	switch mode {
+	case matasano.ECB, matasano.CBC, matasano.CTR, matasano.OFB:
+	default:
+		return nil, fmt.Errorf("unsupported mode %s", mode)
+	}
+	block, err := aes.NewCipher(matasano.RandomBytes(rand, 16))
 	if err != nil {
 		return nil, err
 	}
-	encrypter := matasano.NewECBEncrypter(cipher)
-	result := pkcs7.Pad([]byte(plaintext), 16)
-	encrypter.CryptBlocks(result, result)
-	return result, nil
+	return &ProfileService{
+		block:   block,
+		mode:    mode,
+		rand:    rand,
+		users:   make(map[string]*Profile),
+		nextUID: DefaultUID + 1,
+	}, nil
 }
 
-func DecryptProfile(encrypted []byte) (url.Values, error) {
-	cipher, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+var ErrUserExists = errors.New("user already registered")
+
+// Register adds a user with the given email and role to the store and returns their profile.
+func (s *ProfileService) Register(email, role string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[email]; ok {
+		return nil, ErrUserExists
+	}
+	p := &Profile{Email: email, UID: s.nextUID, Role: role}
+	s.nextUID++
+	s.users[email] = p
+	return p, nil
+}
+
+// Lookup returns the profile of a registered user, or nil.
+func (s *ProfileService) Lookup(email string) *Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[email]
+}
+
+// EncryptedProfileFor encrypts the profile for email: the stored one if the user is registered, and
+// ProfileFor(email) otherwise.
+func (s *ProfileService) EncryptedProfileFor(email string) ([]byte, error) {
+	plaintext := ProfileFor(email)
+	if p := s.Lookup(email); p != nil {
+		plaintext = p.Encode()
 	}
-	decrypter := matasano.NewECBDecrypter(cipher)
-	decrypted := make([]byte, len(encrypted))
-	decrypter.CryptBlocks(decrypted, encrypted)
-	plaintext, err := pkcs7.Unpad(decrypted)
+	return s.encrypt([]byte(plaintext)), nil
+}
+
+// DecryptProfile decrypts and parses an encrypted profile.
+func (s *ProfileService) DecryptProfile(encrypted []byte) (*Profile, error) {
+	plaintext, err := s.decrypt(encrypted)
 	if err != nil {
 		return nil, err
 	}
-	return url.ParseQuery(string(plaintext))
+	return ParseProfile(string(plaintext))
+}
+
+func (s *ProfileService) encrypt(plaintext []byte) []byte {
+	if s.mode == matasano.ECB {
+		result := pkcs7.Pad(plaintext, aes.BlockSize)
+		matasano.NewECBEncrypter(s.block).CryptBlocks(result, result)
+		return result
+	}
+	iv := matasano.RandomBytes(s.rand, aes.BlockSize)
+	var body []byte
+	switch s.mode {
+	case matasano.CBC:
+		body = pkcs7.Pad(plaintext, aes.BlockSize)
+		matasano.NewCBCEncrypter(s.block, iv).CryptBlocks(body, body)
+	case matasano.CTR:
+		body = make([]byte, len(plaintext))
+		matasano.NewCTR(s.block, iv).XORKeyStream(body, plaintext)
+	case matasano.OFB:
+		body = make([]byte, len(plaintext))
+		matasano.NewOFB(s.block, iv).XORKeyStream(body, plaintext)
+	}
+	return append(iv, body...)
+}
+
+var errBadCiphertext = errors.New("bad ciphertext length")
+
+func (s *ProfileService) decrypt(encrypted []byte) ([]byte, error) {
+	if s.mode == matasano.ECB {
+		if len(encrypted)%aes.BlockSize != 0 {
+			return nil, errBadCiphertext
+		}
+		decrypted := make([]byte, len(encrypted))
+		matasano.NewECBDecrypter(s.block).CryptBlocks(decrypted, encrypted)
+		return pkcs7.Unpad(decrypted)
+	}
+	if len(encrypted) < aes.BlockSize {
+		return nil, errBadCiphertext
+	}
+	iv, body := encrypted[:aes.BlockSize], encrypted[aes.BlockSize:]
+	decrypted := make([]byte, len(body))
+	switch s.mode {
+	case matasano.CBC:
+		if len(body)%aes.BlockSize != 0 {
+			return nil, errBadCiphertext
+		}
+		matasano.NewCBCDecrypter(s.block, iv).CryptBlocks(decrypted, body)
+		return pkcs7.Unpad(decrypted)
+	case matasano.CTR:
+		matasano.NewCTR(s.block, iv).XORKeyStream(decrypted, body)
+	case matasano.OFB:
+		matasano.NewOFB(s.block, iv).XORKeyStream(decrypted, body)
+	}
+	return decrypted, nil
 }
@@ -0,0 +1,324 @@
+// Package p31 implements the HMAC-SHA1 timing leak described at http://cryptopals.com/sets/4/challenges/31/
+// (and /32/, which is the same thing with a smaller delay).
+package p31
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/matasano"
+)
+
+// Server is an HTTP handler that checks ?file=...&signature=... against HMAC-SHA1(key, file). It responds with
+// a 200 if the signature is valid and a 500 otherwise. The comparison leaks timing information.
+type Server struct {
+	key []byte
+	// Delay is how long insecureCompare sleeps after each matching byte.
+	Delay time.Duration
+}
+
+// NewServer creates a server with a key read from rand (crypto/rand if nil).
+func NewServer(rand io.Reader, delay time.Duration) *Server {
+	return &Server{key: matasano.RandomBytes(rand, 16), Delay: delay}
+}
+
+// MAC returns the correct signature for file. (This is for checking the attack.)
+func (s *Server) MAC(file string) []byte {
+	mac := hmac.New(sha1.New, s.key)
+	mac.Write([]byte(file))
+	return mac.Sum(nil)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sig, err := hex.DecodeString(q.Get("signature"))
+	if err != nil {
+		http.Error(w, "bad signature encoding", http.StatusBadRequest)
+		return
+	}
+	if !insecureCompare(s.MAC(q.Get("file")), sig, s.Delay) {
+		http.Error(w, "invalid signature", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("OK\n"))
+}
+
+// insecureCompare compares byte-at-a-time, sleeping after each byte and returning as soon as there's a
+// mismatch. (Each sleep is until a deadline rather than for delay so that the error in the individual sleeps
+// doesn't add up -- otherwise with a small delay the noise overwhelms the signal after a few bytes.)
+func insecureCompare(b1, b2 []byte, delay time.Duration) bool {
+	if len(b1) != len(b2) {
+		return false
+	}
+	start := time.Now()
+	for i := range b1 {
+		if b1[i] != b2[i] {
+			return false
+		}
+		time.Sleep(time.Until(start.Add(time.Duration(i+1) * delay)))
+	}
+	return true
+}
+
+// A Statistic reduces a set of timing samples to a single number.
+type Statistic func(samples []time.Duration) time.Duration
+
+// Median is a Statistic. It returns 0 if there are no samples.
+func Median(samples []time.Duration) time.Duration {
+	sorted := sortedCopy(samples)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// TrimmedMean returns a Statistic that takes the mean after discarding the fraction frac of the samples from
+// each end. frac must be in [0, 0.5) so that something is left. Like Median, the Statistic returns 0 if there
+// are no samples.
+func TrimmedMean(frac float64) (Statistic, error) {
+	if !(frac >= 0 && frac < 0.5) {
+		return nil, fmt.Errorf("trimmed fraction %v is not in [0, 0.5)", frac)
+	}
+	return func(samples []time.Duration) time.Duration {
+		sorted := sortedCopy(samples)
+		if len(sorted) == 0 {
+			return 0
+		}
+		trim := int(float64(len(sorted)) * frac)
+		sorted = sorted[trim : len(sorted)-trim]
+		var total time.Duration
+		for _, d := range sorted {
+			total += d
+		}
+		return total / time.Duration(len(sorted))
+	}, nil
+}
+
+func sortedCopy(samples []time.Duration) []time.Duration {
+	sorted := append([]time.Duration{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// Attacker recovers a valid signature from a Server byte-by-byte. For each position, it times requests for
+// all 256 candidate values of that byte (Samples times each) and picks the candidate with the largest
+// Statistic. If it later becomes clear that a byte was wrong (the next byte's candidates aren't any slower),
+// it backs up and tries again.
+type Attacker struct {
+	URL         string       // Base URL of the server
+	Client      *http.Client // If nil, http.DefaultClient is used
+	Samples     int          // Must be positive
+	Statistic   Statistic
+	Concurrency int       // Number of simultaneous requests (default 1)
+	Rand        io.Reader // Source for shuffling the requests (crypto/rand if nil)
+}
+
+const (
+	macSize     = sha1.Size
+	finalists   = 16 // Number of top candidates to re-time
+	finalRounds = 4  // Multiple of Samples to use for re-timing finalists
+	maxRetries  = 20 // Number of times we'll back up or retry a byte before giving up
+)
+
+func (a *Attacker) RecoverMAC(file string) ([]byte, error) {
+	if a.Samples <= 0 {
+		return nil, fmt.Errorf("bad number of samples %d", a.Samples)
+	}
+	if a.Statistic == nil {
+		return nil, errors.New("no Statistic")
+	}
+	sig := make([]byte, macSize)
+	// baselines[i] is the typical response time over all the candidates for byte i, and gaps[i] is how much
+	// slower the chosen candidate was than the others.
+	baselines := make([]time.Duration, macSize)
+	gaps := make([]time.Duration, macSize)
+	retries := 0
+	for i := 0; i < macSize; {
+		if retries > maxRetries {
+			return nil, errors.New("too much noise; gave up after too many retries")
+		}
+		if i == macSize-1 {
+			// There's no timing difference on the last byte. Just check each one.
+			ok, err := a.tryLast(file, sig)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return sig, nil
+			}
+			// We must have gotten an earlier byte wrong.
+			retries++
+			i--
+			continue
+		}
+		best, baseline, gap, err := a.guess(file, sig, i)
+		if err != nil {
+			return nil, err
+		}
+		// If we got the previous byte right, every candidate for this byte should be slower than the candidates
+		// for the previous byte by about as much as the previous winner was. If not, that was a fluke; back up
+		// and try it again.
+		if i > 0 && baseline-baselines[i-1] < gaps[i-1]/2 {
+			retries++
+			i--
+			continue
+		}
+		// If the winner didn't stand out as much as the last one did, it's probably noise; try again.
+		if gap <= 0 || (i > 0 && gap < gaps[i-1]/2) {
+			retries++
+			continue
+		}
+		sig[i] = best
+		baselines[i] = baseline
+		gaps[i] = gap
+		i++
+	}
+	panic("unreached")
+}
+
+// tryLast tries every value for the last byte of sig and reports whether any is accepted. If so, sig is left
+// holding the valid signature.
+func (a *Attacker) tryLast(file string, sig []byte) (bool, error) {
+	for c := 0; c < 256; c++ {
+		sig[macSize-1] = byte(c)
+		_, ok, err := a.try(file, sig)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// guess picks the most likely value for sig[i]. It times all the candidates, and then, because the slowest
+// of 256 noisy measurements is often just noise, re-times the slowest few with more samples before
+// choosing. It also returns the median time over all the candidates and how much slower the winner was than
+// the other finalists.
+func (a *Attacker) guess(file string, sig []byte, i int) (best byte, baseline, gap time.Duration, err error) {
+	candidates := make([]byte, 256)
+	for c := range candidates {
+		candidates[c] = byte(c)
+	}
+	stats, err := a.time(file, sig, i, candidates, a.Samples)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	baseline = Median(stats)
+
+	sort.Slice(candidates, func(j, k int) bool { return stats[candidates[j]] > stats[candidates[k]] })
+	candidates = candidates[:finalists]
+	stats, err = a.time(file, sig, i, candidates, a.Samples*finalRounds)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	finalStats := make([]time.Duration, len(candidates))
+	for j, c := range candidates {
+		finalStats[j] = stats[c]
+		if stats[c] > stats[best] {
+			best = c
+		}
+	}
+	// The re-timing runs under different load, so measure the gap against the other finalists.
+	return best, baseline, stats[best] - Median(finalStats), nil
+}
+
+// time takes samples timing measurements for each of the candidate values of sig[i] and returns the
+// Statistic of each (indexed by candidate).
+func (a *Attacker) time(file string, sig []byte, i int, candidates []byte, samples int) ([]time.Duration, error) {
+	timings := make([][]time.Duration, 256)
+	concurrency := a.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+	)
+	for s := 0; s < samples; s++ {
+		// Shuffle each round. Slowdowns (GC, for instance) tend to hit a run of consecutive requests, and if the
+		// order is the same every round they'll keep hitting the same candidates.
+		for _, j := range a.perm(len(candidates)) {
+			c := candidates[j]
+			candidate := append([]byte{}, sig...)
+			candidate[i] = c
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(c byte) {
+				defer func() { <-sem; wg.Done() }()
+				d, _, err := a.try(file, candidate)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				timings[c] = append(timings[c], d)
+			}(c)
+		}
+		// Finish each round before starting the next so that candidates are measured under similar load.
+		wg.Wait()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	stats := make([]time.Duration, 256)
+	for _, c := range candidates {
+		stats[c] = a.Statistic(timings[c])
+	}
+	return stats, nil
+}
+
+// perm returns a random permutation of [0, n) read from a.Rand.
+func (a *Attacker) perm(n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		j := matasano.RandomIntn(a.Rand, i+1)
+		p[i] = p[j]
+		p[j] = i
+	}
+	return p
+}
+
+// try makes a single request and reports how long it took and whether the signature was accepted.
+func (a *Attacker) try(file string, sig []byte) (time.Duration, bool, error) {
+	u := fmt.Sprintf("%s?file=%s&signature=%s", a.URL, url.QueryEscape(file), hex.EncodeToString(sig))
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Get(u)
+	if err != nil {
+		return 0, false, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return elapsed, true, nil
+	case http.StatusInternalServerError:
+		return elapsed, false, nil
+	}
+	return 0, false, fmt.Errorf("unexpected response status: %s", resp.Status)
+}
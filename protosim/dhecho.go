@@ -0,0 +1,300 @@
+package protosim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/dh"
+	"github.com/cespare/matasano/pkcs7"
+)
+
+// These are the messages used by the DH echo protocols from http://cryptopals.com/sets/5/challenges/34/ and
+// /35/.
+type (
+	// Params starts the protocol in #34: Alice's group and public value.
+	Params struct {
+		P *big.Int
+		G *big.Int
+		A *big.Int
+	}
+	// Negotiate starts the protocol in #35: Alice proposes a group.
+	Negotiate struct {
+		P *big.Int
+		G *big.Int
+	}
+	// Ack is Bob's reply to Negotiate.
+	Ack struct{}
+	// PublicKey carries a public value.
+	PublicKey struct {
+		Y *big.Int
+	}
+	// Ciphertext is an AES-CBC encrypted message along with its IV.
+	Ciphertext struct {
+		Data []byte
+		IV   []byte
+	}
+)
+
+// Encrypt encrypts msg with AES-CBC keyed by the shared secret s, with an IV read from rand (crypto/rand if
+// nil).
+func Encrypt(rand io.Reader, s *big.Int, msg []byte) (Ciphertext, error) {
+	block, err := dh.NewCipher(s)
+	if err != nil {
+		return Ciphertext{}, err
+	}
+	ct := Ciphertext{
+		Data: pkcs7.Pad(msg, block.BlockSize()),
+		IV:   matasano.RandomBytes(rand, block.BlockSize()),
+	}
+	matasano.NewCBCEncrypter(block, ct.IV).CryptBlocks(ct.Data, ct.Data)
+	return ct, nil
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(s *big.Int, ct Ciphertext) ([]byte, error) {
+	block, err := dh.NewCipher(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(ct.Data)%block.BlockSize() != 0 || len(ct.IV) != block.BlockSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	decrypted := make([]byte, len(ct.Data))
+	matasano.NewCBCDecrypter(block, ct.IV).CryptBlocks(decrypted, ct.Data)
+	return pkcs7.Unpad(decrypted)
+}
+
+// The parties below take their private keys and IVs from rand (crypto/rand if nil). The parties run
+// concurrently, so for reproducible runs give each its own source.
+
+// DHEchoAlice is Alice's side of the protocol from #34: she sends her group and public value, gets Bob's
+// public value back, then sends msg encrypted under the shared secret and checks that Bob echoes it.
+func DHEchoAlice(rand io.Reader, group *dh.Group, msg []byte) Party {
+	return func(c *Conn) error {
+		key, err := group.GenerateKey(rand)
+		if err != nil {
+			return err
+		}
+		c.Send(Params{P: group.P, G: group.G, A: key.Y})
+		var pub PublicKey
+		if err := c.Expect(&pub); err != nil {
+			return err
+		}
+		return echo(rand, c, key.SharedSecret(pub.Y), msg)
+	}
+}
+
+// DHEchoBob is Bob's side of the protocol from #34.
+func DHEchoBob(rand io.Reader) Party {
+	return func(c *Conn) error {
+		var params Params
+		if err := c.Expect(&params); err != nil {
+			return err
+		}
+		if err := checkPositive(params.P, params.G, params.A); err != nil {
+			return err
+		}
+		key, err := (&dh.Group{P: params.P, G: params.G}).GenerateKey(rand)
+		if err != nil {
+			return err
+		}
+		c.Send(PublicKey{Y: key.Y})
+		return echoBack(rand, c, key.SharedSecret(params.A))
+	}
+}
+
+// NegotiatedAlice is Alice's side of the protocol from #35, where the group is negotiated before the public
+// values are exchanged.
+func NegotiatedAlice(rand io.Reader, group *dh.Group, msg []byte) Party {
+	return func(c *Conn) error {
+		c.Send(Negotiate{P: group.P, G: group.G})
+		if err := c.Expect(&Ack{}); err != nil {
+			return err
+		}
+		key, err := group.GenerateKey(rand)
+		if err != nil {
+			return err
+		}
+		c.Send(PublicKey{Y: key.Y})
+		var pub PublicKey
+		if err := c.Expect(&pub); err != nil {
+			return err
+		}
+		return echo(rand, c, key.SharedSecret(pub.Y), msg)
+	}
+}
+
+// NegotiatedBob is Bob's side of the protocol from #35.
+func NegotiatedBob(rand io.Reader) Party {
+	return func(c *Conn) error {
+		var neg Negotiate
+		if err := c.Expect(&neg); err != nil {
+			return err
+		}
+		if err := checkPositive(neg.P, neg.G); err != nil {
+			return err
+		}
+		c.Send(Ack{})
+		var pub PublicKey
+		if err := c.Expect(&pub); err != nil {
+			return err
+		}
+		if err := checkPositive(pub.Y); err != nil {
+			return err
+		}
+		key, err := (&dh.Group{P: neg.P, G: neg.G}).GenerateKey(rand)
+		if err != nil {
+			return err
+		}
+		c.Send(PublicKey{Y: key.Y})
+		return echoBack(rand, c, key.SharedSecret(pub.Y))
+	}
+}
+
+// checkPositive returns an error unless every value in ns is present and positive. That doesn't make a group
+// or public value safe (Mallory's bad g's pass), but it keeps a malformed message from crashing a party.
+func checkPositive(ns ...*big.Int) error {
+	for _, n := range ns {
+		if n == nil || n.Sign() <= 0 {
+			return errors.New("malformed group or public value")
+		}
+	}
+	return nil
+}
+
+// echo sends msg and checks that it comes back.
+func echo(rand io.Reader, c *Conn, s *big.Int, msg []byte) error {
+	ct, err := Encrypt(rand, s, msg)
+	if err != nil {
+		return err
+	}
+	c.Send(ct)
+	if err := c.Expect(&ct); err != nil {
+		return err
+	}
+	echoed, err := Decrypt(s, ct)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(echoed, msg) {
+		return errors.New("echoed message does not match")
+	}
+	return nil
+}
+
+// echoBack receives a message and sends it back (with a new IV).
+func echoBack(rand io.Reader, c *Conn, s *big.Int) error {
+	var ct Ciphertext
+	if err := c.Expect(&ct); err != nil {
+		return err
+	}
+	msg, err := Decrypt(s, ct)
+	if err != nil {
+		return err
+	}
+	ct, err = Encrypt(rand, s, msg)
+	if err != nil {
+		return err
+	}
+	c.Send(ct)
+	return nil
+}
+
+// ParameterInjection is the Mallory from #34. She replaces both public values with p, so both parties
+// compute s = p^x mod p = 0, and then she can read everything (without changing it).
+type ParameterInjection struct {
+	p         *big.Int
+	Recovered [][]byte
+}
+
+func (m *ParameterInjection) Intercept(from, to string, msg Message) (Message, bool) {
+	switch msg := msg.(type) {
+	case Params:
+		m.p = msg.P
+		msg.A = msg.P
+		return msg, true
+	case PublicKey:
+		return PublicKey{Y: m.p}, true
+	case Ciphertext:
+		if plaintext, err := Decrypt(big.NewInt(0), msg); err == nil {
+			m.Recovered = append(m.Recovered, plaintext)
+		}
+	}
+	return msg, true
+}
+
+// A MaliciousG says which generator a GeneratorInjection substitutes.
+type MaliciousG int
+
+const (
+	GOne       MaliciousG = iota // g = 1
+	GP                           // g = p
+	GPMinusOne                   // g = p - 1
+)
+
+// GeneratorInjection is the Mallory from #35. She changes the g that Bob sees, which makes his public value
+// B one of a few predictable values, and so Alice's secret B^a is predictable as well. She also replaces
+// Alice's public value with the bad g so that Bob's secret is g^b = B. Knowing both secrets, she can decrypt
+// everything and re-encrypt it for the other party so that the protocol still completes.
+//
+// If g = p - 1 and B = p - 1, then B^a is 1 or p - 1 depending on the parity of a, and Alice's ciphertext
+// doesn't settle which: under the wrong key it still has valid padding about 1/256 of the time. So in that
+// case Mallory sends Alice B = 1 instead (which Bob might have sent himself), making her secret 1.
+type GeneratorInjection struct {
+	G    MaliciousG
+	Rand io.Reader // Source for the IVs of re-encrypted messages (crypto/rand if nil)
+
+	p        *big.Int
+	g        *big.Int
+	aliceKey *big.Int
+	bobKey   *big.Int
+
+	Recovered [][]byte
+}
+
+func (m *GeneratorInjection) Intercept(from, to string, msg Message) (Message, bool) {
+	switch msg := msg.(type) {
+	case Negotiate:
+		m.p = msg.P
+		switch m.G {
+		case GOne:
+			m.g = big.NewInt(1)
+		case GP:
+			m.g = msg.P
+		case GPMinusOne:
+			m.g = new(big.Int).Sub(msg.P, big.NewInt(1))
+		}
+		msg.G = m.g
+		return msg, true
+	case PublicKey:
+		if from == "Alice" {
+			return PublicKey{Y: m.g}, true
+		}
+		m.bobKey = msg.Y
+		m.aliceKey = msg.Y
+		if m.G == GPMinusOne {
+			m.aliceKey = big.NewInt(1)
+			return PublicKey{Y: m.aliceKey}, true
+		}
+	case Ciphertext:
+		if m.bobKey == nil {
+			break
+		}
+		key, other := m.bobKey, m.aliceKey
+		if from == "Alice" {
+			key, other = other, key
+		}
+		plaintext, err := Decrypt(key, msg)
+		if err != nil {
+			return msg, true
+		}
+		m.Recovered = append(m.Recovered, plaintext)
+		if reencrypted, err := Encrypt(m.Rand, other, plaintext); err == nil {
+			return reencrypted, true
+		}
+	}
+	return msg, true
+}
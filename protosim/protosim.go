@@ -0,0 +1,180 @@
+// Package protosim simulates two parties (Alice and Bob) running a protocol over a network that a third party
+// (Mallory) controls. Each party runs in its own goroutine and sends and receives typed messages; Mallory
+// sees every message in flight and may pass it along, drop it, or deliver something else instead.
+package protosim
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A Message is anything sent between parties. Protocols define their own message types.
+type Message interface{}
+
+// ErrClosed is returned by Conn.Recv when the other party has finished and there are no more messages.
+var ErrClosed = errors.New("connection closed by peer")
+
+// Conn is one party's end of the connection.
+type Conn struct {
+	name string
+	in   <-chan Message
+	out  chan<- Message
+}
+
+func (c *Conn) Name() string { return c.name }
+
+func (c *Conn) Send(m Message) { c.out <- m }
+
+func (c *Conn) Recv() (Message, error) {
+	m, ok := <-c.in
+	if !ok {
+		return nil, ErrClosed
+	}
+	return m, nil
+}
+
+// Expect receives the next message into the value pointed to by v, which must have the same type as the
+// message.
+func (c *Conn) Expect(v interface{}) error {
+	m, err := c.Recv()
+	if err != nil {
+		return err
+	}
+	dst := reflect.ValueOf(v).Elem()
+	mv := reflect.ValueOf(m)
+	if !mv.IsValid() || mv.Type() != dst.Type() {
+		return fmt.Errorf("expected message of type %s; got %T", dst.Type(), m)
+	}
+	dst.Set(mv)
+	return nil
+}
+
+// A Party runs one side of a protocol.
+type Party func(c *Conn) error
+
+// A Mallory sits between Alice and Bob.
+type Mallory interface {
+	// Intercept is called for each message in flight. It returns the message to deliver to the recipient
+	// (which may be m itself, a modified copy, or something else entirely) or false to drop it. Calls are
+	// serialized, so implementations don't need to do their own locking.
+	Intercept(from, to string, m Message) (Message, bool)
+}
+
+// MalloryFunc adapts an ordinary function to the Mallory interface.
+type MalloryFunc func(from, to string, m Message) (Message, bool)
+
+func (f MalloryFunc) Intercept(from, to string, m Message) (Message, bool) { return f(from, to, m) }
+
+// A Record is a transcript entry for a single message.
+type Record struct {
+	From      string
+	To        string
+	Sent      Message
+	Delivered Message // nil if the message was dropped
+}
+
+// A Transcript is the record of every message sent during a run, in the order Mallory saw them.
+type Transcript []Record
+
+func (t Transcript) String() string {
+	var s string
+	for _, r := range t {
+		s += fmt.Sprintf("%s -> %s: %#v", r.From, r.To, r.Sent)
+		switch {
+		case r.Delivered == nil:
+			s += " (dropped)"
+		case !reflect.DeepEqual(r.Delivered, r.Sent):
+			s += fmt.Sprintf(" (delivered as %#v)", r.Delivered)
+		}
+		s += "\n"
+	}
+	return s
+}
+
+// Inbox capacity; this just needs to be large enough that both parties can send at once without deadlocking.
+const bufferSize = 64
+
+type endpoint struct {
+	name  string
+	party Party
+	in    chan Message
+	out   chan Message
+	done  chan struct{}
+	err   error
+}
+
+func newEndpoint(name string, party Party) *endpoint {
+	return &endpoint{
+		name:  name,
+		party: party,
+		in:    make(chan Message, bufferSize),
+		out:   make(chan Message),
+		done:  make(chan struct{}),
+	}
+}
+
+// Run runs alice and bob to completion, with every message passing through mallory. If mallory is nil,
+// messages are delivered unchanged. It returns the transcript along with an error if either party failed.
+func Run(alice, bob Party, mallory Mallory) (Transcript, error) {
+	a := newEndpoint("Alice", alice)
+	b := newEndpoint("Bob", bob)
+
+	var (
+		mu         sync.Mutex // Protects Mallory and transcript
+		transcript Transcript
+		wg         sync.WaitGroup
+	)
+	relay := func(from, to *endpoint) {
+		defer wg.Done()
+		// Once from is finished, to won't get any more messages.
+		defer close(to.in)
+		for m := range from.out {
+			mu.Lock()
+			delivered, ok := m, true
+			if mallory != nil {
+				delivered, ok = mallory.Intercept(from.name, to.name, m)
+			}
+			rec := Record{From: from.name, To: to.name, Sent: m}
+			if ok {
+				rec.Delivered = delivered
+			}
+			transcript = append(transcript, rec)
+			mu.Unlock()
+			if !ok {
+				continue
+			}
+			select {
+			case to.in <- delivered:
+			case <-to.done:
+			}
+		}
+	}
+	run := func(e *endpoint) {
+		defer wg.Done()
+		defer close(e.done)
+		defer close(e.out)
+		// A party choking on a malformed message (from Mallory, say) shouldn't take down the whole process.
+		defer func() {
+			if r := recover(); r != nil {
+				e.err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		e.err = e.party(&Conn{name: e.name, in: e.in, out: e.out})
+	}
+
+	wg.Add(4)
+	go relay(a, b)
+	go relay(b, a)
+	go run(a)
+	go run(b)
+	wg.Wait()
+
+	for _, e := range []*endpoint{a, b} {
+		if e.err != nil {
+			return transcript, fmt.Errorf("%s: %s", e.name, e.err)
+		}
+	}
+	return transcript, nil
+}
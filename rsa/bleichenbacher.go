@@ -0,0 +1,204 @@
+package rsa
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/cespare/matasano"
+)
+
+// EncryptPKCS1v15 pads msg as 00 02 [nonzero random bytes] 00 msg and encrypts it. The random bytes come
+// from rand (crypto/rand if nil).
+func (k *PublicKey) EncryptPKCS1v15(rand io.Reader, msg []byte) (*big.Int, error) {
+	rand = matasano.RandOrDefault(rand)
+	size := k.Size()
+	if len(msg) > size-11 {
+		return nil, errors.New("message too long")
+	}
+	block := make([]byte, size)
+	block[1] = 2
+	ps := block[2 : size-len(msg)-1]
+	if _, err := io.ReadFull(rand, ps); err != nil {
+		return nil, err
+	}
+	for i := range ps {
+		for ps[i] == 0 {
+			if _, err := io.ReadFull(rand, ps[i:i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	copy(block[size-len(msg):], msg)
+	return k.Encrypt(new(big.Int).SetBytes(block)), nil
+}
+
+// UnpadPKCS1v15 extracts the message from a PKCS#1 v1.5 encryption block of the given size.
+func UnpadPKCS1v15(m *big.Int, size int) ([]byte, error) {
+	block := leftPad(m.Bytes(), size)
+	if len(block) != size || block[0] != 0 || block[1] != 2 {
+		return nil, errors.New("invalid PKCS#1 v1.5 padding")
+	}
+	for i := 2; i < len(block); i++ {
+		if block[i] == 0 {
+			if i < 10 {
+				break
+			}
+			return block[i+1:], nil
+		}
+	}
+	return nil, errors.New("invalid PKCS#1 v1.5 padding")
+}
+
+// A PaddingOracle tells whether a ciphertext decrypts to something that starts with 00 02.
+type PaddingOracle interface {
+	Conforming(c *big.Int) bool
+}
+
+// PKCS1Oracle is the PaddingOracle from http://cryptopals.com/sets/6/challenges/47/. It counts how many
+// times it is queried.
+type PKCS1Oracle struct {
+	key     *PrivateKey
+	queries int
+}
+
+func NewPKCS1Oracle(key *PrivateKey) *PKCS1Oracle { return &PKCS1Oracle{key: key} }
+
+func (o *PKCS1Oracle) Conforming(c *big.Int) bool {
+	o.queries++
+	block := leftPad(o.key.Decrypt(c).Bytes(), o.key.Size())
+	return block[0] == 0 && block[1] == 2
+}
+
+// Queries returns the number of times Conforming has been called.
+func (o *PKCS1Oracle) Queries() int { return o.queries }
+
+type interval struct {
+	a *big.Int
+	b *big.Int
+}
+
+// BleichenbacherAttack decrypts c, which must be PKCS#1 v1.5 conforming, using only a padding oracle. This is
+// the attack from Bleichenbacher's "Chosen Ciphertext Attacks Against Protocols Based on the RSA Encryption
+// Standard PKCS #1" (1998); the step numbers refer to that paper. It returns the padded plaintext.
+func BleichenbacherAttack(pub *PublicKey, c *big.Int, oracle PaddingOracle) (*big.Int, error) {
+	n := pub.N
+	k := pub.Size()
+	B := new(big.Int).Lsh(one, uint(8*(k-2)))
+	B2 := new(big.Int).Mul(B, two)
+	B3 := new(big.Int).Mul(B, three)
+	B3Minus1 := new(big.Int).Sub(B3, one)
+
+	// tryS reports whether c * s^e is conforming.
+	tryS := func(s *big.Int) bool {
+		c1 := pub.Encrypt(s)
+		c1.Mul(c1, c)
+		c1.Mod(c1, n)
+		return oracle.Conforming(c1)
+	}
+
+	// Step 1: blinding. Our c is already conforming, so s0 = 1.
+	if !oracle.Conforming(c) {
+		return nil, errors.New("ciphertext is not PKCS#1 conforming")
+	}
+	M := []interval{{new(big.Int).Set(B2), new(big.Int).Set(B3Minus1)}}
+	var s *big.Int
+
+	for i := 1; ; i++ {
+		switch {
+		case i == 1:
+			// Step 2a: find the smallest s >= n/3B that's conforming.
+			s = ceilDiv(n, B3)
+			for !tryS(s) {
+				s.Add(s, one)
+			}
+		case len(M) > 1:
+			// Step 2b: keep searching upward.
+			s = new(big.Int).Add(s, one)
+			for !tryS(s) {
+				s.Add(s, one)
+			}
+		default:
+			// Step 2c: with only one interval left, we can search much more efficiently by picking small r
+			// and then trying the s values that would put m*s - r*n in [2B, 3B).
+			a, b := M[0].a, M[0].b
+			r := new(big.Int).Mul(b, s)
+			r.Sub(r, B2)
+			r.Mul(r, two)
+			r = ceilDiv(r, n)
+		search:
+			for ; ; r.Add(r, one) {
+				rn := new(big.Int).Mul(r, n)
+				lo := ceilDiv(new(big.Int).Add(B2, rn), b)
+				hi := ceilDiv(new(big.Int).Add(B3, rn), a)
+				for s = lo; s.Cmp(hi) < 0; s.Add(s, one) {
+					if tryS(s) {
+						break search
+					}
+				}
+			}
+		}
+
+		// Step 3: narrow the set of solutions.
+		var next []interval
+		for _, iv := range M {
+			rLo := new(big.Int).Mul(iv.a, s)
+			rLo.Sub(rLo, B3Minus1)
+			rLo = ceilDiv(rLo, n)
+			rHi := new(big.Int).Mul(iv.b, s)
+			rHi.Sub(rHi, B2)
+			rHi.Div(rHi, n)
+			for r := rLo; r.Cmp(rHi) <= 0; r = new(big.Int).Add(r, one) {
+				rn := new(big.Int).Mul(r, n)
+				a := ceilDiv(new(big.Int).Add(B2, rn), s)
+				if a.Cmp(iv.a) < 0 {
+					a = iv.a
+				}
+				b := new(big.Int).Add(B3Minus1, rn)
+				b.Div(b, s)
+				if b.Cmp(iv.b) > 0 {
+					b = iv.b
+				}
+				if a.Cmp(b) <= 0 {
+					next = append(next, interval{a, b})
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil, errors.New("no intervals left; something went wrong")
+		}
+		M = mergeIntervals(next)
+
+		// Step 4: are we done?
+		if len(M) == 1 && M[0].a.Cmp(M[0].b) == 0 {
+			return M[0].a, nil
+		}
+	}
+}
+
+// mergeIntervals returns the union of ivs as a sorted list of disjoint intervals.
+func mergeIntervals(ivs []interval) []interval {
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].a.Cmp(ivs[j].a) < 0 })
+	merged := []interval{ivs[0]}
+	for _, iv := range ivs[1:] {
+		last := &merged[len(merged)-1]
+		if iv.a.Cmp(last.b) <= 0 {
+			if iv.b.Cmp(last.b) > 0 {
+				last.b = iv.b
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// ceilDiv returns ceil(x / y) for y > 0.
+func ceilDiv(x, y *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(x, y, new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, one)
+	}
+	return q
+}
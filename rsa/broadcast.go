@@ -0,0 +1,57 @@
+package rsa
+
+import (
+	"errors"
+	"math/big"
+)
+
+// CRT uses the Chinese Remainder Theorem to find the x in [0, m1*m2*...) such that x = residues[i] mod
+// moduli[i] for each i. The moduli must be pairwise coprime.
+func CRT(residues, moduli []*big.Int) (*big.Int, error) {
+	if len(residues) != len(moduli) || len(moduli) == 0 {
+		return nil, errors.New("need the same (non-zero) number of residues and moduli")
+	}
+	product := big.NewInt(1)
+	for _, m := range moduli {
+		product.Mul(product, m)
+	}
+	result := new(big.Int)
+	for i, m := range moduli {
+		// ms is the product of all the other moduli.
+		ms := new(big.Int).Quo(product, m)
+		inv, err := InvMod(ms, m)
+		if err != nil {
+			return nil, errors.New("moduli are not pairwise coprime")
+		}
+		term := new(big.Int).Mul(residues[i], ms)
+		term.Mul(term, inv)
+		result.Add(result, term)
+	}
+	return result.Mod(result, product), nil
+}
+
+// BroadcastAttack is Håstad's broadcast attack from http://cryptopals.com/sets/5/challenges/40/. Given the
+// same message encrypted to three different public keys with e = 3, it combines the ciphertexts with the CRT
+// to get m^3 (which is smaller than the product of the moduli, so there's no modular reduction going on) and
+// takes the cube root.
+func BroadcastAttack(ciphertexts []*big.Int, keys []*PublicKey) (*big.Int, error) {
+	if len(ciphertexts) != 3 || len(keys) != 3 {
+		return nil, errors.New("need exactly three ciphertexts and keys")
+	}
+	moduli := make([]*big.Int, len(keys))
+	for i, k := range keys {
+		if k.E.Cmp(three) != 0 {
+			return nil, errors.New("the broadcast attack requires e = 3")
+		}
+		moduli[i] = k.N
+	}
+	cubed, err := CRT(ciphertexts, moduli)
+	if err != nil {
+		return nil, err
+	}
+	m := CubeRoot(cubed)
+	if new(big.Int).Exp(m, three, nil).Cmp(cubed) != 0 {
+		return nil, errors.New("result is not a perfect cube")
+	}
+	return m, nil
+}
@@ -0,0 +1,65 @@
+package rsa
+
+import "math/big"
+
+// A ParityOracle tells whether a ciphertext decrypts to an even number.
+type ParityOracle interface {
+	IsEven(c *big.Int) bool
+}
+
+// LSBOracle is the ParityOracle from http://cryptopals.com/sets/6/challenges/46/. It counts how many times
+// it is queried.
+type LSBOracle struct {
+	key     *PrivateKey
+	queries int
+}
+
+func NewLSBOracle(key *PrivateKey) *LSBOracle { return &LSBOracle{key: key} }
+
+func (o *LSBOracle) IsEven(c *big.Int) bool {
+	o.queries++
+	return o.key.Decrypt(c).Bit(0) == 0
+}
+
+// Queries returns the number of times IsEven has been called.
+func (o *LSBOracle) Queries() int { return o.queries }
+
+// ParityAttack decrypts c using a ParityOracle. Multiplying c by 2^e doubles the plaintext; since n is odd,
+// 2m mod n is even if and only if 2m < n (no wraparound). So each doubling tells us which half of the
+// remaining range m lies in. The bounds are kept as exact rationals so there's no rounding trouble at the
+// end.
+//
+// If hollywood is non-nil, it's called with the current upper bound after every step (for printing the
+// message as it's "decrypted").
+func ParityAttack(pub *PublicKey, c *big.Int, oracle ParityOracle, hollywood func(upper *big.Int)) *big.Int {
+	// Invariant: lo <= m < hi.
+	lo := new(big.Rat)
+	hi := new(big.Rat).SetInt(pub.N)
+	doubler := pub.Encrypt(two)
+	c = new(big.Int).Set(c)
+	half := big.NewRat(1, 2)
+	for i := 0; i < pub.N.BitLen(); i++ {
+		c.Mul(c, doubler)
+		c.Mod(c, pub.N)
+		mid := new(big.Rat).Add(lo, hi)
+		mid.Mul(mid, half)
+		if oracle.IsEven(c) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+		if hollywood != nil {
+			hollywood(ratFloor(hi))
+		}
+	}
+	// Now hi - lo < 1, so m is the only integer in [lo, hi).
+	m := ratFloor(lo)
+	if new(big.Rat).SetInt(m).Cmp(lo) < 0 {
+		m.Add(m, one)
+	}
+	return m
+}
+
+func ratFloor(r *big.Rat) *big.Int {
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
@@ -0,0 +1,159 @@
+// Package rsa implements textbook (unpadded) RSA as described at http://cryptopals.com/sets/5/challenges/39/,
+// along with the number theory it needs and the attacks from later challenges.
+//
+// Don't confuse this with crypto/rsa.
+package rsa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+)
+
+var (
+	one   = big.NewInt(1)
+	two   = big.NewInt(2)
+	three = big.NewInt(3)
+)
+
+type PublicKey struct {
+	N *big.Int
+	E *big.Int
+}
+
+type PrivateKey struct {
+	PublicKey
+	D *big.Int
+}
+
+// Size returns the size of the modulus in bytes.
+func (k *PublicKey) Size() int { return (k.N.BitLen() + 7) / 8 }
+
+// GenerateKey creates a key with a bits-bit modulus and public exponent e, using rand (crypto/rand if nil).
+// It picks new primes until e is invertible mod (p-1)(q-1), which is only possible if e is odd and at least 3.
+func GenerateKey(rand io.Reader, bits int, e int64) (*PrivateKey, error) {
+	if bits < 16 {
+		return nil, errors.New("key too small")
+	}
+	if e < 3 || e%2 == 0 {
+		return nil, fmt.Errorf("bad public exponent %d: must be odd and at least 3", e)
+	}
+	E := big.NewInt(e)
+	for {
+		p, err := GeneratePrime(rand, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := GeneratePrime(rand, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).Mul(p, q)
+		if p.Cmp(q) == 0 || n.BitLen() != bits {
+			continue
+		}
+		et := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d, err := InvMod(E, et)
+		if err != nil {
+			continue
+		}
+		return &PrivateKey{PublicKey: PublicKey{N: n, E: E}, D: d}, nil
+	}
+}
+
+// GeneratePrime returns a random prime with exactly bits bits, using rand (crypto/rand if nil). The top two
+// bits are always set so that the product of two such primes has exactly twice as many bits.
+func GeneratePrime(rand io.Reader, bits int) (*big.Int, error) {
+	if bits < 3 {
+		return nil, errors.New("prime size must be at least 3 bits")
+	}
+	rand = matasano.RandOrDefault(rand)
+	buf := make([]byte, (bits+7)/8)
+	excess := uint(len(buf)*8 - bits)
+	p := new(big.Int)
+	for {
+		if _, err := io.ReadFull(rand, buf); err != nil {
+			return nil, err
+		}
+		buf[0] &= byte(0xff >> excess)
+		// Set the top two bits and make it odd.
+		p.SetBytes(buf)
+		p.SetBit(p, bits-1, 1)
+		p.SetBit(p, bits-2, 1)
+		p.SetBit(p, 0, 1)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// EGCD is the extended Euclidean algorithm: it returns g = gcd(a, b) along with x and y such that
+// ax + by = g.
+func EGCD(a, b *big.Int) (g, x, y *big.Int) {
+	// Invariants: a*x0 + b*y0 = r0 and a*x1 + b*y1 = r1.
+	r0, r1 := new(big.Int).Set(a), new(big.Int).Set(b)
+	x0, x1 := big.NewInt(1), big.NewInt(0)
+	y0, y1 := big.NewInt(0), big.NewInt(1)
+	q := new(big.Int)
+	for r1.Sign() != 0 {
+		q.Quo(r0, r1)
+		r0, r1 = r1, new(big.Int).Sub(r0, new(big.Int).Mul(q, r1))
+		x0, x1 = x1, new(big.Int).Sub(x0, new(big.Int).Mul(q, x1))
+		y0, y1 = y1, new(big.Int).Sub(y0, new(big.Int).Mul(q, y1))
+	}
+	return r0, x0, y0
+}
+
+// InvMod returns the inverse of a mod m.
+func InvMod(a, m *big.Int) (*big.Int, error) {
+	g, x, _ := EGCD(new(big.Int).Mod(a, m), m)
+	if g.Cmp(one) != 0 {
+		return nil, errors.New("not invertible")
+	}
+	return x.Mod(x, m), nil
+}
+
+// Encrypt computes m^e mod n.
+func (k *PublicKey) Encrypt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, k.E, k.N)
+}
+
+// Decrypt computes c^d mod n.
+func (k *PrivateKey) Decrypt(c *big.Int) *big.Int {
+	return new(big.Int).Exp(c, k.D, k.N)
+}
+
+// Root returns the floor of the nth root of x. x must be non-negative and n must be positive; it panics
+// otherwise.
+func Root(x *big.Int, n int) *big.Int {
+	if x.Sign() < 0 {
+		panic("root of a negative number")
+	}
+	if n < 1 {
+		panic(fmt.Sprintf("bad root degree %d", n))
+	}
+	if x.Sign() == 0 {
+		return new(big.Int)
+	}
+	// Newton's method, starting from something that's definitely too big. Each step is
+	// y = ((n-1)r + x/r^(n-1)) / n, and we stop when that stops decreasing.
+	N := big.NewInt(int64(n))
+	nMinus1 := big.NewInt(int64(n - 1))
+	r := new(big.Int).Lsh(one, uint((x.BitLen()+n-1)/n))
+	for {
+		y := new(big.Int).Exp(r, nMinus1, nil)
+		y.Quo(x, y)
+		y.Add(y, new(big.Int).Mul(nMinus1, r))
+		y.Quo(y, N)
+		if y.Cmp(r) >= 0 {
+			return r
+		}
+		r = y
+	}
+}
+
+// CubeRoot returns the floor of the cube root of x.
+func CubeRoot(x *big.Int) *big.Int { return Root(x, 3) }
@@ -0,0 +1,95 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"math/big"
+)
+
+// sha1Prefix is the ASN.1 DER encoding of the DigestInfo header for a SHA-1 hash (see RFC 3447, section
+// 9.2).
+var sha1Prefix = []byte{0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14}
+
+// SignPKCS1v15 signs the SHA-1 hash of msg using PKCS#1 v1.5 padding:
+// 00 01 FF FF ... FF 00 ASN.1 HASH.
+func (k *PrivateKey) SignPKCS1v15(msg []byte) ([]byte, error) {
+	h := sha1.Sum(msg)
+	size := k.Size()
+	tLen := len(sha1Prefix) + len(h)
+	if size < tLen+11 {
+		return nil, errors.New("key too small to sign")
+	}
+	block := make([]byte, size)
+	block[1] = 1
+	for i := 2; i < size-tLen-1; i++ {
+		block[i] = 0xff
+	}
+	copy(block[size-tLen:], sha1Prefix)
+	copy(block[size-len(h):], h[:])
+	sig := k.Decrypt(new(big.Int).SetBytes(block))
+	return leftPad(sig.Bytes(), size), nil
+}
+
+// VerifyPKCS1v15Sloppy checks a PKCS#1 v1.5 SHA-1 signature the way the broken verifier in
+// http://cryptopals.com/sets/6/challenges/42/ does: it looks for 00 01, skips any number of FF bytes, then
+// looks for 00 ASN.1 HASH. But it doesn't check that the hash is at the end of the block, so anything may
+// follow it.
+func (k *PublicKey) VerifyPKCS1v15Sloppy(msg, sig []byte) bool {
+	block := leftPad(k.Encrypt(new(big.Int).SetBytes(sig)).Bytes(), k.Size())
+	if block[0] != 0 || block[1] != 1 {
+		return false
+	}
+	i := 2
+	for i < len(block) && block[i] == 0xff {
+		i++
+	}
+	block = block[i:]
+	if len(block) == 0 || block[0] != 0 {
+		return false
+	}
+	block = block[1:]
+	if !bytes.HasPrefix(block, sha1Prefix) {
+		return false
+	}
+	block = block[len(sha1Prefix):]
+	h := sha1.Sum(msg)
+	return bytes.HasPrefix(block, h[:])
+}
+
+// ForgePKCS1v15 is Bleichenbacher's e=3 signature forgery. It builds the block
+// 00 01 FF 00 ASN.1 HASH 00 00 ... 00 and takes the cube root, rounding up. Cubing the result gives the same
+// prefix followed by garbage, which the sloppy verifier accepts.
+func ForgePKCS1v15(pub *PublicKey, msg []byte) ([]byte, error) {
+	if pub.E.Cmp(three) != 0 {
+		return nil, errors.New("forgery requires e = 3")
+	}
+	h := sha1.Sum(msg)
+	size := pub.Size()
+	block := make([]byte, size)
+	prefix := append([]byte{0x00, 0x01, 0xff, 0x00}, sha1Prefix...)
+	prefix = append(prefix, h[:]...)
+	copy(block, prefix)
+
+	target := new(big.Int).SetBytes(block)
+	sig := CubeRoot(target)
+	if new(big.Int).Exp(sig, three, nil).Cmp(target) != 0 {
+		sig.Add(sig, one)
+	}
+	// Make sure the garbage didn't overflow into the prefix.
+	forged := leftPad(new(big.Int).Exp(sig, three, nil).Bytes(), size)
+	if len(forged) != size || !bytes.HasPrefix(forged, prefix) {
+		return nil, errors.New("key is too small for forgery")
+	}
+	return leftPad(sig.Bytes(), size), nil
+}
+
+// leftPad pads b with zeros on the left to size bytes.
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,69 @@
+package rsa
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/cespare/matasano"
+)
+
+// ErrAlreadyDecrypted is returned by DecryptionService when a ciphertext is submitted a second time.
+var ErrAlreadyDecrypted = errors.New("ciphertext has already been decrypted")
+
+// DecryptionService is the server from http://cryptopals.com/sets/6/challenges/41/. It decrypts whatever it
+// is given, but only once per ciphertext.
+type DecryptionService struct {
+	key *PrivateKey
+
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]bool
+}
+
+func NewDecryptionService(key *PrivateKey) *DecryptionService {
+	return &DecryptionService{key: key, seen: make(map[[sha256.Size]byte]bool)}
+}
+
+// PublicKey returns the service's public key.
+func (s *DecryptionService) PublicKey() *PublicKey { return &s.key.PublicKey }
+
+// Decrypt decrypts c unless it has seen c before.
+func (s *DecryptionService) Decrypt(c *big.Int) (*big.Int, error) {
+	h := sha256.Sum256(c.Bytes())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[h] {
+		return nil, ErrAlreadyDecrypted
+	}
+	s.seen[h] = true
+	return s.key.Decrypt(c), nil
+}
+
+// RecoverUnpadded gets service to decrypt c even though it has already been decrypted once. It submits
+// C' = s^e * C mod N for random s (read from rand, or crypto/rand if nil), which decrypts to P' = s * P mod N,
+// and then divides out s.
+func RecoverUnpadded(rand io.Reader, service *DecryptionService, c *big.Int) (*big.Int, error) {
+	pub := service.PublicKey()
+	// Pick s in [2, N).
+	s, err := matasano.RandomNonzero(rand, new(big.Int).Sub(pub.N, one))
+	if err != nil {
+		return nil, err
+	}
+	s.Add(s, one)
+	sInv, err := InvMod(s, pub.N)
+	if err != nil {
+		// We found a factor of N. Let's not bother with that.
+		return nil, err
+	}
+	cPrime := pub.Encrypt(s)
+	cPrime.Mul(cPrime, c)
+	cPrime.Mod(cPrime, pub.N)
+	pPrime, err := service.Decrypt(cPrime)
+	if err != nil {
+		return nil, err
+	}
+	p := pPrime.Mul(pPrime, sInv)
+	return p.Mod(p, pub.N), nil
+}
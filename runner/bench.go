@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// takeOracleCounts returns the total queries made to the instrumented and counted oracles since the last
+// call and forgets about those oracles. ok is false if there weren't any.
+func takeOracleCounts() (calls, bytesSent int64, ok bool) {
+	oracles.Lock()
+	defer oracles.Unlock()
+	for _, c := range oracles.counters {
+		calls += c.Calls()
+		bytesSent += c.BytesSent()
+	}
+	ok = len(oracles.counters) > 0
+	oracles.counters = nil
+	return calls, bytesSent, ok
+}
+
+// A benchResult is the total cost of running a problem several times.
+type benchResult struct {
+	problem *problemInfo
+	err     error // The first failure, which stops the benchmark
+	skipped bool  // Not run at all (err says why)
+	runs    int
+	wall    time.Duration
+	allocs  uint64
+	bytes   uint64 // Bytes allocated
+
+	instrumented bool // Whether the problem used any instrumented oracles
+	calls        int64
+	bytesSent    int64
+
+	timedOut bool // The last run timed out and is still going
+}
+
+// benchProblem runs p n times, stopping at the first failure. Allocations are counted over the whole
+// process, so nothing else should be running at the same time.
+func benchProblem(p *problemInfo, n int, timeout time.Duration) benchResult {
+	b := benchResult{problem: p}
+	takeOracleCounts()
+	for i := 0; i < n; i++ {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		r := runProblem(p, timeout)
+		runtime.ReadMemStats(&after)
+		calls, bytesSent, ok := takeOracleCounts()
+		if r.err != nil {
+			b.err = r.err
+			b.timedOut = r.timedOut
+			return b
+		}
+		b.runs++
+		b.wall += r.duration
+		b.allocs += after.Mallocs - before.Mallocs
+		b.bytes += after.TotalAlloc - before.TotalAlloc
+		if ok {
+			b.instrumented = true
+			b.calls += calls
+			b.bytesSent += bytesSent
+		}
+	}
+	return b
+}
+
+// benchAll benchmarks each problem in turn and writes the results to w as they come in. It reports whether
+// any problem failed.
+func benchAll(problems []*problemInfo, n int, timeout time.Duration, format string, w io.Writer) (failed bool) {
+	var print func(b benchResult)
+	switch format {
+	case "text":
+		fmt.Fprintf(w, "%-3s %4s %14s %12s %14s %14s %14s\n",
+			"id", "runs", "time/op", "allocs/op", "B/op", "queries/op", "sent B/op")
+		print = func(b benchResult) { printBenchText(w, b) }
+	case "json":
+		enc := json.NewEncoder(w)
+		print = func(b benchResult) { enc.Encode(newBenchJSON(b)) }
+	default:
+		panic("unsupported benchmark format " + format)
+	}
+	for i, p := range problems {
+		b := benchProblem(p, n, timeout)
+		if b.err != nil {
+			failed = true
+		}
+		print(b)
+		if b.timedOut {
+			// There's no stopping the problem, and it would add its allocations and oracle queries to whatever
+			// ran next, so the rest of the measurements would be tainted.
+			err := fmt.Errorf("problem %d timed out and is still running, so the results would be tainted", p.id)
+			for _, q := range problems[i+1:] {
+				print(benchResult{problem: q, err: err, skipped: true})
+			}
+			return true
+		}
+	}
+	return failed
+}
+
+func printBenchText(w io.Writer, b benchResult) {
+	if b.skipped {
+		fmt.Fprintf(w, "%-3d \033[91mSkipped: %s\033[0m\n", b.problem.id, b.err)
+		return
+	}
+	if b.err != nil {
+		fmt.Fprintf(w, "%-3d \033[91mError on run %d: %s\033[0m\n", b.problem.id, b.runs+1, b.err)
+		return
+	}
+	runs := uint64(b.runs)
+	queries, sent := "-", "-"
+	if b.instrumented {
+		queries = fmt.Sprint(b.calls / int64(b.runs))
+		sent = fmt.Sprint(b.bytesSent / int64(b.runs))
+	}
+	fmt.Fprintf(w, "%-3d %4d %14s %12d %14d %14s %14s\n",
+		b.problem.id, b.runs, b.wall/time.Duration(b.runs), b.allocs/runs, b.bytes/runs, queries, sent)
+}
+
+type benchJSON struct {
+	ID          int     `json:"id"`
+	Set         int     `json:"set"`
+	Title       string  `json:"title"`
+	Status      string  `json:"status"`                 // "pass", "fail", or "skip"
+	Message     string  `json:"message"`                // The error, if a run failed
+	Runs        int     `json:"runs"`                   // Successful runs
+	Duration    float64 `json:"duration"`               // Mean seconds per run
+	Allocs      uint64  `json:"allocs"`                 // Per run
+	Bytes       uint64  `json:"bytes"`                  // Allocated per run
+	OracleCalls *int64  `json:"oracle_calls,omitempty"` // Per run, if the problem uses an instrumented oracle
+	OracleBytes *int64  `json:"oracle_bytes,omitempty"` // Sent per run
+}
+
+func newBenchJSON(b benchResult) benchJSON {
+	bj := benchJSON{
+		ID:     b.problem.id,
+		Set:    b.problem.set,
+		Title:  b.problem.title,
+		Status: "pass",
+		Runs:   b.runs,
+	}
+	switch {
+	case b.skipped:
+		bj.Status = "skip"
+		bj.Message = b.err.Error()
+	case b.err != nil:
+		bj.Status = "fail"
+		bj.Message = b.err.Error()
+	}
+	if b.runs == 0 {
+		return bj
+	}
+	bj.Duration = (b.wall / time.Duration(b.runs)).Seconds()
+	bj.Allocs = b.allocs / uint64(b.runs)
+	bj.Bytes = b.bytes / uint64(b.runs)
+	if b.instrumented {
+		calls := b.calls / int64(b.runs)
+		sent := b.bytesSent / int64(b.runs)
+		bj.OracleCalls = &calls
+		bj.OracleBytes = &sent
+	}
+	return bj
+}
@@ -1,42 +1,136 @@
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const maxPrintLen = 100
 
-type Problem func() (string, error)
+// parseIDs parses a list of problem ids and ranges like "1,3,9-14". Only registered ids are kept, so a range
+// like 1-1000000000 costs no more than 1-66.
+func parseIDs(s string) (map[int]bool, error) {
+	problems := registered()
+	ids := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		lo, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("bad problem id %q", part)
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			hi, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil || hi < lo {
+				return nil, fmt.Errorf("bad problem range %q", part)
+			}
+		}
+		for _, p := range problems {
+			if p.id >= lo && p.id <= hi {
+				ids[p.id] = true
+			}
+		}
+	}
+	return ids, nil
+}
 
 func main() {
-	for _, p := range []struct {
-		id int
-		f  Problem
-	}{
-		{1, Problem1},
-		{2, Problem2},
-		{3, Problem3},
-		{4, Problem4},
-		{5, Problem5},
-		{6, Problem6},
-		{7, Problem7},
-		{8, Problem8},
-		{9, Problem9},
-		{10, Problem10},
-		{11, Problem11},
-		{12, Problem12},
-		{13, Problem13},
-		{14, Problem14},
-	} {
-		fmt.Printf("%-2d ", p.id)
-		color := "\033[92m"
-		msg, err := p.f()
+	var (
+		ids     = flag.String("p", "", "Problems to run: an id (6), a range (9-14), or a list of those (1,3,9-14)")
+		set     = flag.Int("set", 0, "Only run problems from this set")
+		list    = flag.Bool("list", false, "List the selected problems instead of running them")
+		format  = flag.String("format", "text", "Output format: text, json (one object per line), or tap")
+		workers = flag.Int("j", 1, "Number of problems to run in parallel (timing attacks may suffer)")
+		timeout = flag.Duration("timeout", 5*time.Minute, "Give up on a problem after this long (0 for no limit)")
+		record  = flag.String("record", "", "Record transcripts of the problems' oracle queries in this directory")
+		replay  = flag.String("replay", "", "Answer oracle queries from transcripts in this directory (made with -record)")
+		remote  = flag.String("remote", "", "Query the oracles served by oracleserver at this URL (e.g. http://localhost:8011)")
+		seedF   = flag.Int64("seed", 0, "Seed the problems' keys and other randomness so that runs are reproducible (0: use crypto/rand)")
+		bench   = flag.Int("bench", 0, "Run each problem this many times (one problem at a time) and report the costs")
+		budget  = flag.Int64("budget", 0, "Allow each instrumented oracle only this many queries (0: no limit)")
+		flaky   = flag.Float64("flaky", 0, "Make this fraction of the queries to instrumented oracles fail")
+		retry   = flag.Int("retries", 0, "Try each query to an instrumented oracle up to this many times")
+		latency = flag.Duration("latency", 0, "Delay each query to an instrumented oracle by up to this long")
+		logQ    = flag.Bool("log", false, "Log each query to an instrumented oracle to stderr")
+	)
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rep, err := newReporter(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *bench < 0 || (*bench > 0 && *format == "tap") {
+		fmt.Fprintln(os.Stderr, "-bench must be positive and only supports the text and json formats")
+		os.Exit(2)
+	}
+	if *record != "" && *replay != "" {
+		fmt.Fprintln(os.Stderr, "-record and -replay can't be used together")
+		os.Exit(2)
+	}
+	if *budget < 0 || *flaky < 0 || *flaky >= 1 || *retry < 0 || *latency < 0 {
+		fmt.Fprintln(os.Stderr, "-budget, -retries, and -latency must not be negative, and -flaky must be in [0, 1)")
+		os.Exit(2)
+	}
+	recordDir, replayDir, remoteURL = *record, *replay, *remote
+	seed = *seedF
+	queryBudget, failureRate, retries, queryLatency = *budget, *flaky, *retry, *latency
+	if *logQ {
+		logQueries = os.Stderr
+	}
+
+	var selected map[int]bool
+	if *ids != "" {
+		selected, err = parseIDs(*ids)
 		if err != nil {
-			color = "\033[91m"
-			msg = fmt.Sprintf("Error: %s", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	var toRun []*problemInfo
+	for _, p := range registered() {
+		if selected != nil && !selected[p.id] {
+			continue
+		}
+		if *set != 0 && p.set != *set {
+			continue
+		}
+		toRun = append(toRun, p)
+	}
+	if len(toRun) == 0 {
+		fmt.Fprintln(os.Stderr, "No problems selected.")
+		os.Exit(2)
+	}
+
+	if *list {
+		for _, p := range toRun {
+			fmt.Printf("%-2d (set %d) %s\n", p.id, p.set, p.title)
 		}
-		// Don't print the whole thing if it's long. (Like the lyrics to an entire song...)
-		if len(msg) > maxPrintLen {
-			msg = msg[:maxPrintLen-5] + "[...]"
+		if g := gaps(); g != "" {
+			fmt.Printf("Not implemented: %s\n", g)
 		}
-		fmt.Printf("%s%s\033[0m\n", color, msg)
+		return
+	}
+
+	var failed bool
+	if *bench > 0 {
+		benchmarking = true
+		failed = benchAll(toRun, *bench, *timeout, *format, os.Stdout)
+	} else {
+		failed = runAll(toRun, *workers, *timeout, rep)
+	}
+	if err := closeTranscripts(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing transcripts:", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/oraclehttp"
+)
+
+// These are set from flags before any problems run.
+var (
+	benchmarking bool   // Count queries to instrumented oracles
+	recordDir    string // Record instrumented oracles' transcripts in this directory
+	replayDir    string // Replace instrumented oracles with transcripts from this directory
+	remoteURL    string // Replace instrumented oracles with the ones served by oracleserver at this URL
+
+	// These make instrumented oracles misbehave (see stress).
+	queryBudget  int64         // Allow this many queries to each oracle (0 for no limit)
+	failureRate  float64       // Fail this fraction of queries
+	retries      int           // Try each query up to this many times (0 or 1 for no retries)
+	queryLatency time.Duration // Delay each query by up to this long
+	logQueries   io.Writer     // Log each query here (nil for no logging)
+)
+
+// oracles holds the oracles instrumented (or counted with countQueries) during the current benchmark run.
+// Benchmarks run one problem at a time, so everything in here belongs to the problem being measured.
+var oracles struct {
+	sync.Mutex
+	counters []queryCounter
+}
+
+// A queryCounter reports how much an oracle has been queried. *matasano.CountingOracle is one.
+type queryCounter interface {
+	Calls() int64
+	BytesSent() int64
+}
+
+// transcripts holds the open transcript files for -record.
+var transcripts struct {
+	sync.Mutex
+	files map[int]*os.File
+}
+
+// instrument wraps an oracle created by problem id so that the runner can record its queries, replay them,
+// send them to a remote server instead, or count them (see the -record, -replay, -remote, and -bench flags),
+// and then applies stress. If none of those flags are set, it returns oracle unchanged. If a problem
+// instruments several oracles, they share a transcript.
+func instrument(id int, oracle matasano.Oracle) matasano.Oracle {
+	if remoteURL != "" {
+		oracle = oraclehttp.NewClient(fmt.Sprintf("%s/%d", strings.TrimSuffix(remoteURL, "/"), id))
+	}
+	switch {
+	case replayDir != "":
+		oracle = replayOracle(id)
+	case recordDir != "":
+		f, err := transcriptFile(id)
+		if err != nil {
+			oracle = errOracle{err}
+		} else {
+			oracle = matasano.NewRecordingOracle(oracle, f)
+		}
+	}
+	if benchmarking {
+		counter := matasano.NewCountingOracle(oracle)
+		oracles.Lock()
+		oracles.counters = append(oracles.counters, counter)
+		oracles.Unlock()
+		oracle = counter
+	}
+	return stress(id, oracle)
+}
+
+// A selfCountingOracle is an oracle that isn't a matasano.Oracle (so it can't be instrumented) but counts its
+// own queries, like rsa.PKCS1Oracle and rsa.LSBOracle.
+type selfCountingOracle interface {
+	Queries() int
+}
+
+// countQueries adds the queries made to oracle to the benchmark accounting (see -bench). Each query sends the
+// oracle size bytes.
+func countQueries(oracle selfCountingOracle, size int) {
+	if !benchmarking {
+		return
+	}
+	oracles.Lock()
+	oracles.counters = append(oracles.counters, selfCounter{oracle, int64(size)})
+	oracles.Unlock()
+}
+
+type selfCounter struct {
+	oracle selfCountingOracle
+	size   int64
+}
+
+func (c selfCounter) Calls() int64     { return int64(c.oracle.Queries()) }
+func (c selfCounter) BytesSent() int64 { return c.Calls() * c.size }
+
+// stress wraps an instrumented oracle according to the -budget, -latency, -flaky, -retries, and -log flags,
+// to check that an attack is economical (it fits in a budget) and robust (with retries, it survives
+// failures). The budget only counts queries that reach the oracle, so injected failures don't use it up, and
+// the log shows every try.
+func stress(id int, oracle matasano.Oracle) matasano.Oracle {
+	// The injected failures and latency come from their own stream so that under -seed they don't change the
+	// oracle's keys.
+	rand := problemStream(id, stressStream)
+	if queryBudget > 0 {
+		oracle = matasano.NewBudgetOracle(oracle, queryBudget)
+	}
+	if queryLatency > 0 {
+		latency := matasano.NewLatencyOracle(oracle, 0, queryLatency)
+		latency.Rand = rand
+		oracle = latency
+	}
+	if failureRate > 0 {
+		flaky := matasano.NewFlakyOracle(oracle, failureRate)
+		flaky.Rand = rand
+		oracle = flaky
+	}
+	if logQueries != nil {
+		oracle = matasano.NewLoggingOracle(oracle, logQueries)
+	}
+	if retries > 1 {
+		oracle = matasano.NewRetryingOracle(oracle, retries)
+	}
+	return oracle
+}
+
+// stressStream is the problemStream used for the misbehavior that stress adds.
+const stressStream = 99
+
+func transcriptName(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("problem%02d.jsonl", id))
+}
+
+func replayOracle(id int) matasano.Oracle {
+	f, err := os.Open(transcriptName(replayDir, id))
+	if err != nil {
+		return errOracle{err}
+	}
+	defer f.Close()
+	oracle, err := matasano.NewReplayOracle(f)
+	if err != nil {
+		return errOracle{err}
+	}
+	return oracle
+}
+
+// transcriptFile returns the transcript for problem id, creating it (or truncating an old one) the first
+// time.
+func transcriptFile(id int) (*os.File, error) {
+	transcripts.Lock()
+	defer transcripts.Unlock()
+	if f, ok := transcripts.files[id]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(recordDir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(transcriptName(recordDir, id))
+	if err != nil {
+		return nil, err
+	}
+	if transcripts.files == nil {
+		transcripts.files = make(map[int]*os.File)
+	}
+	transcripts.files[id] = f
+	return f, nil
+}
+
+// closeTranscripts closes the files opened for -record.
+func closeTranscripts() error {
+	transcripts.Lock()
+	defer transcripts.Unlock()
+	var firstErr error
+	for _, f := range transcripts.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	transcripts.files = nil
+	return firstErr
+}
+
+// errOracle fails every query. It stands in for an oracle that couldn't be set up, so that the error is
+// reported by the problem that uses it.
+type errOracle struct {
+	err error
+}
+
+func (o errOracle) Encrypt([]byte) ([]byte, error) { return nil, o.err }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A Problem solves one challenge and returns a short description of the result.
+type Problem func() (string, error)
+
+// problemInfo is a registered problem and its metadata.
+type problemInfo struct {
+	id    int
+	set   int
+	title string
+	files []string // Data files the problem reads
+	f     Problem
+}
+
+var registry = make(map[int]*problemInfo)
+
+// Each set has 8 problems.
+const setSize = 8
+
+// Register adds a problem to the registry. Each set file calls this from init for each of its problems. It
+// panics if the id is already registered or doesn't belong to set, since either means there's a typo.
+func Register(set, id int, title string, f Problem, files ...string) {
+	if _, ok := registry[id]; ok {
+		panic(fmt.Sprintf("problem %d registered twice", id))
+	}
+	if (id-1)/setSize+1 != set {
+		panic(fmt.Sprintf("problem %d registered in set %d", id, set))
+	}
+	registry[id] = &problemInfo{id: id, set: set, title: title, files: files, f: f}
+}
+
+// registered returns all the registered problems, sorted by id.
+func registered() []*problemInfo {
+	var problems []*problemInfo
+	for _, p := range registry {
+		problems = append(problems, p)
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].id < problems[j].id })
+	return problems
+}
+
+// gaps describes the ids between 1 and the largest registered id that have no problem, like "15-30, 32".
+func gaps() string {
+	var ranges []string
+	problems := registered()
+	next := 1
+	for _, p := range problems {
+		switch {
+		case p.id == next+1:
+			ranges = append(ranges, fmt.Sprint(next))
+		case p.id > next+1:
+			ranges = append(ranges, fmt.Sprintf("%d-%d", next, p.id-1))
+		}
+		next = p.id + 1
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// missingFiles returns the data files needed by p that don't exist.
+func (p *problemInfo) missingFiles() []string {
+	var missing []string
+	for _, name := range p.files {
+		if _, err := os.Stat(name); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A result is the outcome of running one problem.
+type result struct {
+	problem  *problemInfo
+	output   string
+	err      error
+	duration time.Duration
+	timedOut bool // The problem gave up on p.f, which is still running in the background
+}
+
+// A reporter prints results as they come in.
+type reporter interface {
+	start(n int) // Called once with the number of problems to be run
+	report(r result)
+	finish()
+}
+
+func newReporter(format string, w io.Writer) (reporter, error) {
+	switch format {
+	case "text":
+		return &textReporter{w}, nil
+	case "json":
+		return &jsonReporter{json.NewEncoder(w)}, nil
+	case "tap":
+		return &tapReporter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (must be text, json, or tap)", format)
+}
+
+// textReporter prints colored, truncated results for humans.
+type textReporter struct {
+	w io.Writer
+}
+
+func (t *textReporter) start(int) {}
+func (t *textReporter) finish()   {}
+
+func (t *textReporter) report(r result) {
+	color := "\033[92m"
+	msg := r.output
+	if r.err != nil {
+		color = "\033[91m"
+		msg = fmt.Sprintf("Error: %s", r.err)
+	}
+	// Don't print the whole thing if it's long. (Like the lyrics to an entire song...)
+	if len(msg) > maxPrintLen {
+		msg = msg[:maxPrintLen-5] + "[...]"
+	}
+	fmt.Fprintf(t.w, "%-2d %s%s\033[0m\n", r.problem.id, color, msg)
+}
+
+// jsonReporter prints one JSON object per line for each problem.
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+type jsonResult struct {
+	ID       int     `json:"id"`
+	Set      int     `json:"set"`
+	Title    string  `json:"title"`
+	Status   string  `json:"status"`  // "pass" or "fail"
+	Message  string  `json:"message"` // The error, if the problem failed
+	Output   string  `json:"output"`
+	Duration float64 `json:"duration"` // Seconds
+}
+
+func (j *jsonReporter) start(int) {}
+func (j *jsonReporter) finish()   {}
+
+func (j *jsonReporter) report(r result) {
+	jr := jsonResult{
+		ID:       r.problem.id,
+		Set:      r.problem.set,
+		Title:    r.problem.title,
+		Status:   "pass",
+		Output:   r.output,
+		Duration: r.duration.Seconds(),
+	}
+	if r.err != nil {
+		jr.Status = "fail"
+		jr.Message = r.err.Error()
+	}
+	j.enc.Encode(jr)
+}
+
+// tapReporter prints results in the Test Anything Protocol (version 13) format.
+type tapReporter struct {
+	w io.Writer
+	n int
+}
+
+func (t *tapReporter) start(n int) {
+	fmt.Fprintf(t.w, "TAP version 13\n1..%d\n", n)
+}
+
+func (t *tapReporter) finish() {}
+
+func (t *tapReporter) report(r result) {
+	t.n++
+	status := "ok"
+	if r.err != nil {
+		status = "not ok"
+	}
+	fmt.Fprintf(t.w, "%s %d - problem %d (set %d): %s\n", status, t.n, r.problem.id, r.problem.set, r.problem.title)
+	// Details go in a YAML block.
+	fmt.Fprintln(t.w, "  ---")
+	if r.err != nil {
+		fmt.Fprintf(t.w, "  message: %s\n", yamlString(r.err.Error()))
+	}
+	fmt.Fprintf(t.w, "  output: %s\n", yamlString(r.output))
+	fmt.Fprintf(t.w, "  duration_ms: %.3f\n", float64(r.duration)/float64(time.Millisecond))
+	fmt.Fprintln(t.w, "  ...")
+}
+
+// yamlString quotes s as a YAML double-quoted scalar. JSON strings are valid YAML, so we can just use the
+// JSON encoding.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/cespare/matasano"
+)
+
+// seed is set from the -seed flag before any problems run.
+var seed int64
+
+// problemRand returns the source of randomness that problem id should use for its oracles' keys and choices:
+// crypto/rand (nil) normally, or a source derived from -seed so that the problem does the same thing every
+// time.
+func problemRand(id int) io.Reader { return problemStream(id, 0) }
+
+// problemStream is like problemRand, but returns the nth of several independent sources for problem id
+// (problemRand is stream 0). Every call to problemRand(id) returns the same stream under -seed, so anything
+// that should be independent of the problem's main oracle (a second key, say) needs its own stream.
+func problemStream(id, n int) io.Reader {
+	if seed == 0 {
+		return nil
+	}
+	return matasano.SeededRand((seed*1000+int64(id))*100 + int64(n))
+}
+
+// runProblem runs p in its own goroutine, turning a panic into an error and giving up after timeout (if
+// non-zero). There's no way to stop a problem that's taking too long, so it's left running in the background
+// and its result is discarded.
+func runProblem(p *problemInfo, timeout time.Duration) result {
+	if missing := p.missingFiles(); len(missing) > 0 {
+		return result{problem: p, err: fmt.Errorf("missing data files: %s", strings.Join(missing, ", "))}
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- result{problem: p, output: string(debug.Stack()), err: fmt.Errorf("panic: %v", e)}
+			}
+		}()
+		output, err := p.f()
+		done <- result{problem: p, output: output, err: err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r = result{problem: p, err: fmt.Errorf("timed out after %s", timeout), timedOut: true}
+	}
+	r.duration = time.Since(start)
+	return r
+}
+
+// runAll runs problems using a pool of workers and sends the results to rep in order. It reports whether any
+// problem failed.
+func runAll(problems []*problemInfo, workers int, timeout time.Duration, rep reporter) (failed bool) {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]chan result, len(problems))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- runProblem(problems[i], timeout)
+			}
+		}()
+	}
+	go func() {
+		for i := range problems {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	rep.start(len(problems))
+	for _, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			failed = true
+		}
+		rep.report(r)
+	}
+	rep.finish()
+	return failed
+}
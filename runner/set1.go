@@ -10,6 +10,7 @@ import (
 	"math"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/cespare/matasano"
 	"github.com/cespare/matasano/xorcipher"
@@ -19,14 +20,29 @@ const (
 	corpusFilename = "files/the_adventures_of_sherlock_holmes.txt"
 )
 
-var xorCorpus *xorcipher.Corpus
-
 func init() {
-	var err error
-	xorCorpus, err = xorcipher.NewCorpus(corpusFilename)
-	if err != nil {
-		panic(err)
-	}
+	Register(1, 1, "Convert hex to base64", Problem1)
+	Register(1, 2, "Fixed XOR", Problem2)
+	Register(1, 3, "Single-byte XOR cipher", Problem3, corpusFilename)
+	Register(1, 4, "Detect single-character XOR", Problem4, corpusFilename, "files/problem04.txt")
+	Register(1, 5, "Implement repeating-key XOR", Problem5)
+	Register(1, 6, "Break repeating-key XOR", Problem6, corpusFilename, "files/problem06.txt")
+	Register(1, 7, "AES in ECB mode", Problem7, "files/problem07.txt")
+	Register(1, 8, "Detect AES in ECB mode", Problem8, "files/problem08.txt")
+}
+
+var (
+	xorCorpusOnce sync.Once
+	xorCorpus     *xorcipher.Corpus
+	xorCorpusErr  error
+)
+
+// loadXorCorpus loads the corpus the first time it's needed.
+func loadXorCorpus() (*xorcipher.Corpus, error) {
+	xorCorpusOnce.Do(func() {
+		xorCorpus, xorCorpusErr = xorcipher.NewCorpus(corpusFilename)
+	})
+	return xorCorpus, xorCorpusErr
 }
 
 func Problem1() (string, error) {
@@ -82,13 +98,21 @@ func Problem3() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	decrypted, _, _ := xorCorpus.BestBufScore(buf)
+	corpus, err := loadXorCorpus()
+	if err != nil {
+		return "", err
+	}
+	decrypted, _, _ := corpus.BestBufScore(buf)
 	return fmt.Sprintf("Message: %q", decrypted), nil
 }
 
 // Same approach as Problem 3. Just take the best overall score.
 func Problem4() (string, error) {
 	const filename = "files/problem04.txt"
+	corpus, err := loadXorCorpus()
+	if err != nil {
+		return "", err
+	}
 	f, err := os.Open(filename)
 	defer f.Close()
 	if err != nil {
@@ -110,7 +134,7 @@ func Problem4() (string, error) {
 		if err != nil {
 			return "", err
 		}
-		decrypted, _, score := xorCorpus.BestBufScore(encrypted)
+		decrypted, _, score := corpus.BestBufScore(encrypted)
 		if score < bestScore {
 			bestMsg = decrypted
 			bestScore = score
@@ -139,6 +163,10 @@ func Problem5() (string, error) {
 // Just follow the steps.
 func Problem6() (string, error) {
 	const filename = "files/problem06.txt"
+	corpus, err := loadXorCorpus()
+	if err != nil {
+		return "", err
+	}
 	f, err := os.Open(filename)
 	defer f.Close()
 	if err != nil {
@@ -176,7 +204,7 @@ func Problem6() (string, error) {
 		for i := offset; i < len(encrypted); i += keysize {
 			transposed = append(transposed, encrypted[i])
 		}
-		_, c, _ := xorCorpus.BestBufScore(transposed)
+		_, c, _ := corpus.BestBufScore(transposed)
 		key = append(key, c)
 	}
 
@@ -246,23 +274,15 @@ func Problem8() (string, error) {
 	possibleEcb := []int{}
 
 	for i, encrypted := range ciphertexts {
-		seen := make(map[[16]byte]int)
 		if len(encrypted)%16 != 0 {
 			return "", fmt.Errorf("expected encrypted texts to have lengths a multiple of 16")
 		}
-		for len(encrypted) > 0 {
-			var block [16]byte
-			for j := range block {
-				block[j] = encrypted[j]
-			}
-			encrypted = encrypted[16:]
-			seen[block]++
+		a, err := matasano.AnalyzeECB(encrypted, 16)
+		if err != nil {
+			return "", err
 		}
-		for _, count := range seen {
-			if count > 1 {
-				possibleEcb = append(possibleEcb, i)
-				break
-			}
+		if a.ECB() {
+			possibleEcb = append(possibleEcb, i)
 		}
 	}
 
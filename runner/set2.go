@@ -7,12 +7,22 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/cespare/matasano"
 	"github.com/cespare/matasano/p13"
 	"github.com/cespare/matasano/pkcs7"
 )
 
+func init() {
+	Register(2, 9, "Implement PKCS#7 padding", Problem9)
+	Register(2, 10, "Implement CBC mode", Problem10, "files/problem10.txt")
+	Register(2, 11, "An ECB/CBC detection oracle", Problem11)
+	Register(2, 12, "Byte-at-a-time ECB decryption (Simple)", Problem12)
+	Register(2, 13, "ECB cut-and-paste", Problem13)
+	Register(2, 14, "Byte-at-a-time ECB decryption (Harder)", Problem14)
+}
+
 func Problem9() (string, error) {
 	const (
 		block    = "YELLOW SUBMARINE"
@@ -67,23 +77,82 @@ func Problem10() (string, error) {
 	return fmt.Sprintf("Message: %q", decrypted), nil
 }
 
-// I simply call the oracle function with a large input of 0-value bytes (0x0 0x0 ...) and check whether some
-// of the interior blocks of the encrypted result are the same.
+// I simply call the oracle function with a large input of 0-value bytes (0x0 0x0 ...) and check whether the
+// encrypted result has repeated blocks. Besides the challenge's setup, this tries every key size, a few other
+// ranges of random prefix lengths, and mixing in the stream modes. Then, for each mode on its own, it checks
+// that DetectMode (which chooses its own inputs) identifies it.
 func Problem11() (string, error) {
-	const trials = 100
-	success := 0
-	input := make([]byte, 160)
-	for i := 0; i < trials; i++ {
-		encrypted, err, ecb := matasano.AESOracle(input)
-		if err != nil {
-			return "", err
+	const trials = 50
+	var (
+		keySizes = []int{16, 24, 32}
+		prefixes = [][2]int{{5, 10}, {0, 0}, {0, 48}}
+		modeSets = [][]matasano.WeightedMode{
+			matasano.EqualModes(matasano.ECB, matasano.CBC),
+			matasano.EqualModes(matasano.ECB, matasano.CBC, matasano.CTR, matasano.OFB),
 		}
-		if matasano.IsECB(encrypted, 16) == ecb {
-			success++
+		modes = []matasano.Mode{matasano.ECB, matasano.CBC, matasano.CTR, matasano.OFB}
+	)
+	// Bad settings should be rejected, not panic (or fail deep inside crypto/aes).
+	for _, bad := range []matasano.AESOracle{
+		{KeySize: -1},
+		{KeySize: 20},
+		{KeySize: 16, PrefixMin: 3, PrefixMax: 2},
+		{KeySize: 16, SuffixMin: 3, SuffixMax: 2},
+	} {
+		bad.Modes = matasano.EqualModes(matasano.ECB)
+		if _, err := bad.Query(nil); err == nil {
+			return "", fmt.Errorf("expected an error from an oracle configured as %+v", bad)
+		}
+	}
+
+	input := make([]byte, 160)
+	oracle := matasano.NewAESOracle(problemRand(11))
+	success, total := 0, 0
+	detected, detectTotal := 0, 0
+	for _, keySize := range keySizes {
+		for _, prefix := range prefixes {
+			oracle.KeySize = keySize
+			oracle.PrefixMin, oracle.PrefixMax = prefix[0], prefix[1]
+			for _, modeSet := range modeSets {
+				oracle.Modes = modeSet
+				for i := 0; i < trials; i++ {
+					r, err := oracle.Query(input)
+					if err != nil {
+						return "", err
+					}
+					a, err := matasano.AnalyzeECB(r.Ciphertext, 16)
+					if err != nil {
+						return "", err
+					}
+					if a.ECB() == (r.Mode == matasano.ECB) {
+						success++
+					}
+					total++
+				}
+			}
+			for _, mode := range modes {
+				oracle.Modes = matasano.EqualModes(mode)
+				// OFB looks just like CTR from the outside.
+				want := mode
+				if mode == matasano.OFB {
+					want = matasano.CTR
+				}
+				for i := 0; i < trials/5; i++ {
+					got, err := matasano.DetectMode(oracle, 16)
+					if err != nil {
+						return "", err
+					}
+					if got == want {
+						detected++
+					}
+					detectTotal++
+				}
+			}
 		}
 	}
-	msg := fmt.Sprintf("ECB correctly detected %d out of %d times", success, trials)
-	if success == trials {
+	msg := fmt.Sprintf("ECB correctly detected %d out of %d times; mode detected %d out of %d times",
+		success, total, detected, detectTotal)
+	if success == total && detected == detectTotal {
 		return msg, nil
 	}
 	return "", fmt.Errorf(msg)
@@ -100,51 +169,59 @@ YnkK
 	if err != nil {
 		return "", err
 	}
-	oracle := matasano.NewAESOracle2(ciphertext)
+	oracle := matasano.NewCountingOracle(instrument(12, matasano.NewAESOracle2(problemRand(12), ciphertext)))
+	unknown, err := byteAtATimeECB(oracle)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Message: %q (%d oracle queries)\n", unknown, oracle.Calls()), nil
+}
 
+// byteAtATimeECB recovers the unknown bytes that oracle appends to its input before encrypting with ECB.
+func byteAtATimeECB(oracle matasano.Oracle) ([]byte, error) {
 	// First, determine the block size. Just feed in larger and larger input until the encrypted size jumps up.
 	// The difference is the block size.
 	blockSize, err := matasano.DetermineBlockSize(oracle)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Confirm that the oracle is emitting ECB encrypted data.
-	input := make([]byte, blockSize*10)
-	encrypted, err := oracle.Encrypt(input)
+	mode, err := matasano.DetectMode(oracle, blockSize)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if !matasano.IsECB(encrypted, blockSize) {
-		return "", fmt.Errorf("ECB not detected")
+	if mode != matasano.ECB {
+		return nil, fmt.Errorf("ECB not detected (looks like %s)", mode)
 	}
 
 	// Determine the length of the unknown string.
-	encrypted, err = oracle.Encrypt(nil)
+	encrypted, err := oracle.Encrypt(nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	unknownLength := len(encrypted)
 
 	// Now use determine each byte of the unknown input, starting at the beginning.
 	unknown := make([]byte, 0, unknownLength)
 	for len(unknown) < unknownLength {
-		next, err := oracle.ECBNextUnknownByte(unknown, blockSize)
+		next, err := matasano.ECBNextUnknownByte(oracle, unknown, blockSize)
 		if err != nil {
 			// At the end, there's an issue because the input is padded. We'll 'discover' that the next byte is
 			// 0x1, but then in the next iteration we'll fail to find the subsequent byte because the test input is
 			// now padded with 0x2 0x2. We could verify this (keep testing the padding out until we get to
 			// unknownLength) but for now I'm not going to bother, and assume that we've decoded the secret message.
-			if len(unknown) > unknownLength-blockSize && unknown[len(unknown)-1] == byte(1) {
+			// (Any other error, like a failed query, is real.)
+			if err == matasano.ErrNoMatchingByte && len(unknown) > unknownLength-blockSize &&
+				unknown[len(unknown)-1] == byte(1) {
 				unknown = unknown[:len(unknown)-1]
 				break
 			}
-			return "", err
+			return nil, err
 		}
 		unknown = append(unknown, next)
 	}
-
-	return fmt.Sprintf("Message: %q\n", unknown), nil
+	return unknown, nil
 }
 
 func Problem13() (string, error) {
@@ -155,39 +232,160 @@ func Problem13() (string, error) {
 	// N-2: [...........role=]
 	// N-1: [admin....pad....]
 	// We can do this with three blocks by using an email address that's 13 bytes long:
-	// 1: email=abc@defgh.
-	// 2: com&uid=10&role=
+	// 1: email=aaaaaaaaaa
+	// 2: aaa&uid=10&role=
 	// 3: admin...........   (all the . are 0xb)
-	// So we need to separately determine what each of the encrypted values of those blocks are. The first 2 are
-	// the same as if we just call EncryptedProfileFor(abc@defgh.com); we'll use a specially constructed email
-	// address to determine the value of the last block:
+	// The first 2 blocks are the start of the encryption of that profile; the last comes from a specially
+	// constructed email address:
 	// 1: email=aaaaaaaaaa
 	// 2: admin...........    . = 0xb
-	// 3: @whatever.com
-
-	target := make([]byte, blockSize*3)
-	first, err := p13.EncryptedProfileFor("abc@defgh.com")
+	// 3: &uid=10&role=use
+	// matasano.CutAndPaste figures all that out given ProfileFor.
+	profiles, err := p13.NewProfileService(problemRand(13), matasano.ECB)
+	if err != nil {
+		return "", err
+	}
+	forger := &matasano.CutAndPaste{
+		BlockSize: blockSize,
+		Encode:    p13.ProfileFor,
+		Encrypt:   profiles.EncryptedProfileFor,
+	}
+	forged, err := forger.Forge(func(plaintext string) string {
+		return strings.Replace(plaintext, "role=user", "role=admin", 1)
+	})
 	if err != nil {
 		return "", err
 	}
-	copy(target, first[:blockSize*2])
+	profile, err := profiles.DecryptProfile(forged)
+	if err != nil {
+		return "", err
+	}
+	msg := fmt.Sprintf("role: %q", profile.Role)
+	if profile.Role != "admin" {
+		return "", fmt.Errorf(msg)
+	}
 
-	second, err := p13.EncryptedProfileFor("aaaaaaaaaaadmin\x0b\x0b\x0b\x0b\x0b\x0b\x0b\x0b\x0b\x0b\x0b")
+	// The same thing works with the fields in another order and different separators, and with a filler
+	// byte that isn't ASCII.
+	encode := func(email string) string {
+		email = strings.NewReplacer("|", "", ":", "").Replace(email)
+		return fmt.Sprintf("uid:10|email:%s|role:user", email)
+	}
+	// This service's key comes from its own stream so that under -seed it isn't the same as the first one's.
+	cipher, err := aes.NewCipher(matasano.RandomBytes(problemStream(13, 1), 16))
 	if err != nil {
 		return "", err
 	}
-	copy(target[2*blockSize:], second[blockSize:2*blockSize])
+	forger.Encode = encode
+	forger.Filler = 0xff
+	forger.Encrypt = func(email string) ([]byte, error) {
+		result := pkcs7.Pad([]byte(encode(email)), blockSize)
+		matasano.NewECBEncrypter(cipher).CryptBlocks(result, result)
+		return result, nil
+	}
+	forged, err = forger.Forge(func(plaintext string) string {
+		return strings.Replace(plaintext, "role:user", "role:admin", 1)
+	})
+	if err != nil {
+		return "", err
+	}
+	matasano.NewECBDecrypter(cipher).CryptBlocks(forged, forged)
+	decrypted, err := pkcs7.Unpad(forged)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(string(decrypted), "|role:admin") {
+		return "", fmt.Errorf("forgery with reordered fields failed: %q", decrypted)
+	}
+	if _, err := (&matasano.CutAndPaste{Encode: encode}).Forge(strings.ToUpper); err == nil {
+		return "", fmt.Errorf("expected Forge to reject a block size of 0")
+	}
 
-	result, err := p13.DecryptProfile(target)
+	modes, err := profileModes()
 	if err != nil {
 		return "", err
 	}
-	role := result.Get("role")
-	msg := fmt.Sprintf("role: %q", role)
-	if role == "admin" {
-		return msg, nil
+	return msg + "; " + modes, nil
+}
+
+// profileModes checks the rest of the profile service: in every mode, registered users' profiles survive the
+// round trip, but the cut-and-paste forgery only works against ECB. Against the stream modes, though, the
+// attacker knows the plaintext of their own profile, so they can flip bits to rewrite it instead.
+func profileModes() (string, error) {
+	users := []struct{ email, role string }{
+		{"alice@example.com", "admin"},
+		{"bob@example.com", "user"},
 	}
-	return "", fmt.Errorf(msg)
+	var flipped []string
+	for i, mode := range []matasano.Mode{matasano.ECB, matasano.CBC, matasano.CTR, matasano.OFB} {
+		profiles, err := p13.NewProfileService(problemStream(13, 2+i), mode)
+		if err != nil {
+			return "", err
+		}
+		for _, u := range users {
+			want, err := profiles.Register(u.email, u.role)
+			if err != nil {
+				return "", err
+			}
+			encrypted, err := profiles.EncryptedProfileFor(u.email)
+			if err != nil {
+				return "", err
+			}
+			got, err := profiles.DecryptProfile(encrypted)
+			if err != nil {
+				return "", fmt.Errorf("%s: decrypting the profile for %s: %s", mode, u.email, err)
+			}
+			if *got != *want {
+				return "", fmt.Errorf("%s: stored profile %+v came back as %+v", mode, *want, *got)
+			}
+		}
+		if _, err := profiles.Register(users[0].email, "user"); err != p13.ErrUserExists {
+			return "", fmt.Errorf("%s: registering %s twice: got err = %v", mode, users[0].email, err)
+		}
+		if mode == matasano.ECB {
+			continue
+		}
+
+		forger := &matasano.CutAndPaste{
+			BlockSize: aes.BlockSize,
+			Encode:    p13.ProfileFor,
+			Encrypt:   profiles.EncryptedProfileFor,
+		}
+		forged, err := forger.Forge(func(plaintext string) string {
+			return strings.Replace(plaintext, "role=user", "role=admin", 1)
+		})
+		if err == nil {
+			if p, err := profiles.DecryptProfile(forged); err == nil && p.Role == "admin" {
+				return "", fmt.Errorf("%s: cut-and-paste forgery worked", mode)
+			}
+		}
+		if mode == matasano.CBC {
+			continue
+		}
+
+		// With a stream mode, flipping a ciphertext bit flips the same plaintext bit. The service puts the IV
+		// first.
+		const email = "mallory@example.com"
+		encrypted, err := profiles.EncryptedProfileFor(email)
+		if err != nil {
+			return "", err
+		}
+		from, to := "&uid=10&role=user", "&role=admin&uid=1"
+		offset := aes.BlockSize + strings.Index(p13.ProfileFor(email), from)
+		for i := range from {
+			encrypted[offset+i] ^= from[i] ^ to[i]
+		}
+		p, err := profiles.DecryptProfile(encrypted)
+		if err != nil {
+			return "", fmt.Errorf("%s: bit-flipped profile: %s", mode, err)
+		}
+		if p.Role != "admin" {
+			return "", fmt.Errorf("%s: bit-flipped profile has role %q", mode, p.Role)
+		}
+		flipped = append(flipped, mode.String())
+	}
+	return fmt.Sprintf("cut-and-paste fails against the other modes, but bit-flipping works against %s",
+		strings.Join(flipped, " and ")), nil
 }
 
 func Problem14() (string, error) {
@@ -201,7 +399,7 @@ YnkK
 	if err != nil {
 		return "", err
 	}
-	oracle := matasano.NewAESOracle3(ciphertext)
+	oracle := instrument(14, matasano.NewAESOracle3(problemRand(14), ciphertext))
 
 	// Determine block size
 	blockSize, err := matasano.DetermineBlockSize(oracle)
@@ -210,13 +408,12 @@ YnkK
 	}
 
 	// Confirm that the oracle is emitting ECB encrypted data.
-	input := make([]byte, blockSize*10)
-	encrypted, err := oracle.Encrypt(input)
+	mode, err := matasano.DetectMode(oracle, blockSize)
 	if err != nil {
 		return "", err
 	}
-	if !matasano.IsECB(encrypted, blockSize) {
-		return "", fmt.Errorf("ECB not detected")
+	if mode != matasano.ECB {
+		return "", fmt.Errorf("ECB not detected (looks like %s)", mode)
 	}
 
 
@@ -226,7 +423,7 @@ YnkK
 	//// Now use determine each byte of the unknown input, starting at the beginning.
 	//unknown := make([]byte, 0, unknownLength)
 	//for len(unknown) < unknownLength {
-	//next, err := oracle.ECBNextUnknownByte(unknown, blockSize)
+	//next, err := matasano.ECBNextUnknownByte(oracle, unknown, blockSize)
 	//if err != nil {
 	//// At the end, there's an issue because the input is padded. We'll 'discover' that the next byte is
 	//// 0x1, but then in the next iteration we'll fail to find the subsequent byte because the test input is
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/dh"
+	"github.com/cespare/matasano/pkcs7"
+	"github.com/cespare/matasano/protosim"
+	"github.com/cespare/matasano/rsa"
+	"github.com/cespare/matasano/srp"
+)
+
+func init() {
+	Register(5, 33, "Implement Diffie-Hellman", Problem33)
+	Register(5, 34, "Implement a MITM key-fixing attack on Diffie-Hellman with parameter injection", Problem34)
+	Register(5, 35, "Implement DH with negotiated groups, and break with malicious \"g\" parameters", Problem35)
+	Register(5, 36, "Implement Secure Remote Password (SRP)", Problem36)
+	Register(5, 37, "Break SRP with a zero key", Problem37)
+	Register(5, 38, "Offline dictionary attack on simplified SRP", Problem38, corpusFilename)
+	Register(5, 39, "Implement RSA", Problem39)
+	Register(5, 40, "Implement an E=3 RSA Broadcast attack", Problem40)
+}
+
+// Do the toy version with p = 37 first, then the real thing. Both sides should arrive at the same secret, and
+// it should work as an AES key.
+func Problem33() (string, error) {
+	rand := problemRand(33)
+	toy := &dh.Group{P: big.NewInt(37), G: big.NewInt(5)}
+	for _, group := range []*dh.Group{toy, dh.DefaultGroup} {
+		a, err := group.GenerateKey(rand)
+		if err != nil {
+			return "", err
+		}
+		b, err := group.GenerateKey(rand)
+		if err != nil {
+			return "", err
+		}
+		if a.SharedSecret(b.Y).Cmp(b.SharedSecret(a.Y)) != 0 {
+			return "", fmt.Errorf("shared secrets do not match (p = %s)", group.P)
+		}
+	}
+
+	a, err := dh.DefaultGroup.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	b, err := dh.DefaultGroup.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	msg := []byte("Diffie-Hellman-Merkle")
+	iv := matasano.RandomBytes(rand, 16)
+	blockA, err := dh.NewCipher(a.SharedSecret(b.Y))
+	if err != nil {
+		return "", err
+	}
+	encrypted := pkcs7.Pad(msg, 16)
+	matasano.NewCBCEncrypter(blockA, iv).CryptBlocks(encrypted, encrypted)
+
+	blockB, err := dh.NewCipher(b.SharedSecret(a.Y))
+	if err != nil {
+		return "", err
+	}
+	decrypted := make([]byte, len(encrypted))
+	matasano.NewCBCDecrypter(blockB, iv).CryptBlocks(decrypted, encrypted)
+	decrypted, err = pkcs7.Unpad(decrypted)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(decrypted, msg) {
+		return "", fmt.Errorf("message did not survive the round trip")
+	}
+	return "OK", nil
+}
+
+// Run the protocol honestly first to make sure it works, then with Mallory injecting p for the public keys.
+// Both sides end up with s = 0, so Mallory can read everything.
+func Problem34() (string, error) {
+	msg := []byte("Attack at dawn")
+	// Alice and Bob run concurrently, so they each get their own source.
+	aliceRand, bobRand := problemStream(34, 1), problemStream(34, 2)
+	alice := func() protosim.Party { return protosim.DHEchoAlice(aliceRand, dh.DefaultGroup, msg) }
+	if _, err := protosim.Run(alice(), protosim.DHEchoBob(bobRand), nil); err != nil {
+		return "", err
+	}
+
+	mallory := &protosim.ParameterInjection{}
+	if _, err := protosim.Run(alice(), protosim.DHEchoBob(bobRand), mallory); err != nil {
+		return "", err
+	}
+	if len(mallory.Recovered) != 2 {
+		return "", fmt.Errorf("expected Mallory to recover 2 messages; got %d", len(mallory.Recovered))
+	}
+	for _, recovered := range mallory.Recovered {
+		if !bytes.Equal(recovered, msg) {
+			return "", fmt.Errorf("Mallory recovered the wrong message: %q", recovered)
+		}
+	}
+
+	// A clumsier Mallory who sends garbage should just make Bob fail, not crash anything.
+	garbage := protosim.MalloryFunc(func(from, to string, m protosim.Message) (protosim.Message, bool) {
+		if _, ok := m.(protosim.Params); ok {
+			return protosim.Params{}, true
+		}
+		return m, true
+	})
+	if _, err := protosim.Run(alice(), protosim.DHEchoBob(bobRand), garbage); err == nil {
+		return "", fmt.Errorf("expected the protocol to fail with malformed parameters")
+	}
+	return fmt.Sprintf("Mallory read: %q", mallory.Recovered[0]), nil
+}
+
+// With a negotiated group, Mallory can change g. If Bob uses g = 1, p, or p-1, then his public value is 1, 0,
+// or one of 1 and p-1, and so is Alice's secret (except that with g = p-1, Mallory passes 1 on to Alice, since
+// (p-1)^a depends on a). Mallory also sends Bob the bad g as Alice's public value, so his secret is just his
+// public value. Then she can decrypt and re-encrypt in both directions.
+func Problem35() (string, error) {
+	msg := []byte("Attack at dawn")
+	aliceRand, bobRand, malloryRand := problemStream(35, 1), problemStream(35, 2), problemStream(35, 3)
+	// With g = p - 1, the secrets depend on the parity of the private keys, so run each case a few times to
+	// cover the combinations.
+	const runs = 8
+	for _, g := range []protosim.MaliciousG{protosim.GOne, protosim.GP, protosim.GPMinusOne} {
+		for i := 0; i < runs; i++ {
+			mallory := &protosim.GeneratorInjection{G: g, Rand: malloryRand}
+			alice := protosim.NegotiatedAlice(aliceRand, dh.DefaultGroup, msg)
+			if _, err := protosim.Run(alice, protosim.NegotiatedBob(bobRand), mallory); err != nil {
+				return "", err
+			}
+			if len(mallory.Recovered) != 2 {
+				return "", fmt.Errorf("expected Mallory to recover 2 messages; got %d", len(mallory.Recovered))
+			}
+			for _, recovered := range mallory.Recovered {
+				if !bytes.Equal(recovered, msg) {
+					return "", fmt.Errorf("Mallory recovered the wrong message: %q", recovered)
+				}
+			}
+		}
+	}
+	return "OK", nil
+}
+
+// srpLogin runs server and client on either end of a net.Pipe and returns the client's result (or the
+// server's, if the server had some other problem).
+func srpLogin(serve func(io.ReadWriter) error, login func(io.ReadWriter) error) error {
+	serverConn, clientConn := net.Pipe()
+	serverErr := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		serverErr <- serve(serverConn)
+	}()
+	err := login(clientConn)
+	clientConn.Close()
+	if sErr := <-serverErr; sErr != nil && sErr != srp.ErrAuthFailed {
+		return sErr
+	}
+	return err
+}
+
+func Problem36() (string, error) {
+	const (
+		email    = "alice@example.com"
+		password = "hunter2"
+	)
+	// The server and client run concurrently, so they each get their own source.
+	serverRand, clientRand := problemStream(36, 1), problemStream(36, 2)
+	server := srp.NewServer(serverRand, srp.DefaultParams)
+	if err := server.Register(email, password); err != nil {
+		return "", err
+	}
+	err := srpLogin(server.ServeConn, func(conn io.ReadWriter) error {
+		return srp.Login(clientRand, conn, srp.DefaultParams, email, password)
+	})
+	if err != nil {
+		return "", err
+	}
+	err = srpLogin(server.ServeConn, func(conn io.ReadWriter) error {
+		return srp.Login(clientRand, conn, srp.DefaultParams, email, "hunter3")
+	})
+	if err != srp.ErrAuthFailed {
+		return "", fmt.Errorf("expected login with the wrong password to fail; got %v", err)
+	}
+
+	// The client should reject a challenge with no B, or with B = 0 (mod N), which would make S = 0.
+	for _, B := range []string{"null", "0", srp.DefaultParams.N.String()} {
+		badServer := func(conn io.ReadWriter) error {
+			var hello json.RawMessage
+			if err := json.NewDecoder(conn).Decode(&hello); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintf(conn, `{"Salt":"","B":%s}`+"\n", B)
+			return err
+		}
+		err := srpLogin(badServer, func(conn io.ReadWriter) error {
+			return srp.Login(clientRand, conn, srp.DefaultParams, email, password)
+		})
+		if err == nil || err == srp.ErrAuthFailed {
+			return "", fmt.Errorf("B = %s: expected the client to reject the challenge; got %v", B, err)
+		}
+	}
+	return "OK", nil
+}
+
+// If A is 0, N, 2N, ..., then the server computes S = 0 and we can compute the same K without the password.
+func Problem37() (string, error) {
+	const email = "alice@example.com"
+	server := srp.NewServer(problemStream(37, 1), srp.DefaultParams)
+	if err := server.Register(email, string(matasano.RandomBytes(problemRand(37), 20))); err != nil {
+		return "", err
+	}
+	for multiple := int64(0); multiple <= 2; multiple++ {
+		err := srpLogin(server.ServeConn, func(conn io.ReadWriter) error {
+			return srp.LoginWithoutPassword(conn, srp.DefaultParams, email, multiple)
+		})
+		if err != nil {
+			return "", fmt.Errorf("A = %d * N: %s", multiple, err)
+		}
+	}
+	return "OK", nil
+}
+
+// Simplified SRP works fine between honest parties, but if we pose as the server we can pick b = 1, u = 1,
+// and salt = "" and then try dictionary words offline. The dictionary is every word in our English corpus.
+func Problem38() (string, error) {
+	const (
+		email    = "alice@example.com"
+		password = "elementary"
+	)
+	serverRand, clientRand := problemStream(38, 1), problemStream(38, 2)
+	server := srp.NewSimpleServer(serverRand, srp.DefaultParams)
+	if err := server.Register(email, password); err != nil {
+		return "", err
+	}
+	login := func(conn io.ReadWriter) error {
+		return srp.SimpleLogin(clientRand, conn, srp.DefaultParams, email, password)
+	}
+	if err := srpLogin(server.ServeConn, login); err != nil {
+		return "", err
+	}
+
+	mallory := srp.NewMaliciousSimpleServer(srp.DefaultParams)
+	if err := srpLogin(mallory.ServeConn, login); err != srp.ErrAuthFailed {
+		return "", fmt.Errorf("expected the malicious server to reject the login; got %v", err)
+	}
+	dictionary, err := corpusWords()
+	if err != nil {
+		return "", err
+	}
+	cracked, err := mallory.Crack(dictionary)
+	if err != nil {
+		return "", err
+	}
+	if cracked != password {
+		return "", fmt.Errorf("cracked the wrong password: %q", cracked)
+	}
+	return fmt.Sprintf("Password: %q (out of %d words)", cracked, len(dictionary)), nil
+}
+
+// corpusWords returns the distinct lowercase words in the text corpus.
+func corpusWords() ([]string, error) {
+	text, err := ioutil.ReadFile(corpusFilename)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(string(text)), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words, nil
+}
+
+func Problem39() (string, error) {
+	if inv, err := rsa.InvMod(big.NewInt(17), big.NewInt(3120)); err != nil || inv.Int64() != 2753 {
+		return "", fmt.Errorf("invmod(17, 3120) = %v (error: %v); expected 2753", inv, err)
+	}
+	// An even e (or one less than 3) is never invertible mod (p-1)(q-1), so no choice of primes would do.
+	for _, e := range []int64{0, 1, 2, 4} {
+		if _, err := rsa.GenerateKey(problemRand(39), 64, e); err == nil {
+			return "", fmt.Errorf("expected GenerateKey to reject e = %d", e)
+		}
+	}
+	key, err := rsa.GenerateKey(problemRand(39), 1024, 3)
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range []string{"*", "Hello, RSA"} {
+		m := new(big.Int).SetBytes([]byte(msg))
+		decrypted := key.Decrypt(key.Encrypt(m))
+		if string(decrypted.Bytes()) != msg {
+			return "", fmt.Errorf("round trip failed for %q", msg)
+		}
+	}
+	return "OK", nil
+}
+
+// The three moduli are (almost certainly) coprime, so the CRT gives us m^3 mod n1*n2*n3. But m^3 < n1*n2*n3,
+// so that's just m^3. (The message is long enough that m^3 is bigger than any one of the moduli, so we can't
+// just take the cube root of a single ciphertext.)
+func Problem40() (string, error) {
+	rand := problemRand(40)
+	const msg = "Now that the party is jumping, with the bass kicked in and the Vegas are pumpin'"
+	m := new(big.Int).SetBytes([]byte(msg))
+	var (
+		ciphertexts []*big.Int
+		keys        []*rsa.PublicKey
+	)
+	for i := 0; i < 3; i++ {
+		key, err := rsa.GenerateKey(rand, 1024, 3)
+		if err != nil {
+			return "", err
+		}
+		keys = append(keys, &key.PublicKey)
+		ciphertexts = append(ciphertexts, key.Encrypt(m))
+	}
+	recovered, err := rsa.BroadcastAttack(ciphertexts, keys)
+	if err != nil {
+		return "", err
+	}
+	if recovered.Cmp(m) != 0 {
+		return "", fmt.Errorf("recovered the wrong message: %q", recovered.Bytes())
+	}
+	return fmt.Sprintf("Message: %q", recovered.Bytes()), nil
+}
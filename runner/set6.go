@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/dsa"
+	"github.com/cespare/matasano/rsa"
+)
+
+func init() {
+	Register(6, 41, "Implement unpadded message recovery oracle", Problem41)
+	Register(6, 42, "Bleichenbacher's e=3 RSA Attack", Problem42)
+	Register(6, 43, "DSA key recovery from nonce", Problem43)
+	Register(6, 44, "DSA nonce recovery from repeated nonce", Problem44)
+	Register(6, 45, "DSA parameter tampering", Problem45)
+	Register(6, 46, "RSA parity oracle", Problem46)
+	Register(6, 47, "Bleichenbacher's PKCS 1.5 Padding Oracle (Simple Case)", Problem47)
+	Register(6, 48, "Bleichenbacher's PKCS 1.5 Padding Oracle (Complete Case)", Problem48)
+}
+
+// The server has already decrypted our target ciphertext, so it won't do it again. But RSA is multiplicative,
+// so we can ask it to decrypt a disguised version instead.
+func Problem41() (string, error) {
+	rand := problemRand(41)
+	const msg = `{"time": 1356304276, "social": "555-55-5555"}`
+	key, err := rsa.GenerateKey(rand, 1024, 65537)
+	if err != nil {
+		return "", err
+	}
+	service := rsa.NewDecryptionService(key)
+	c := key.Encrypt(new(big.Int).SetBytes([]byte(msg)))
+	if _, err := service.Decrypt(c); err != nil {
+		return "", err
+	}
+	if _, err := service.Decrypt(c); err != rsa.ErrAlreadyDecrypted {
+		return "", fmt.Errorf("expected the service to refuse to decrypt twice; got %v", err)
+	}
+
+	p, err := rsa.RecoverUnpadded(rand, service, c)
+	if err != nil {
+		return "", err
+	}
+	if string(p.Bytes()) != msg {
+		return "", fmt.Errorf("recovered the wrong message: %q", p.Bytes())
+	}
+	return fmt.Sprintf("Message: %q", p.Bytes()), nil
+}
+
+// The verifier doesn't check that the hash is right-justified, so we can put the hash near the front of the
+// block and let whatever comes after it be garbage. Then we just need a number whose cube starts with the
+// right bytes, which we can get by taking the cube root (rounding up) of the block we want.
+func Problem42() (string, error) {
+	rand := problemRand(42)
+	const msg = "hi mom"
+	key, err := rsa.GenerateKey(rand, 1024, 3)
+	if err != nil {
+		return "", err
+	}
+	sig, err := key.SignPKCS1v15([]byte(msg))
+	if err != nil {
+		return "", err
+	}
+	if !key.VerifyPKCS1v15Sloppy([]byte(msg), sig) {
+		return "", fmt.Errorf("real signature did not verify")
+	}
+
+	forged, err := rsa.ForgePKCS1v15(&key.PublicKey, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	if !key.VerifyPKCS1v15Sloppy([]byte(msg), forged) {
+		return "", fmt.Errorf("forged signature did not verify")
+	}
+	if key.VerifyPKCS1v15Sloppy([]byte("hi dad"), forged) {
+		return "", fmt.Errorf("forged signature verified for the wrong message")
+	}
+	return fmt.Sprintf("Forged signature: %x", bytes.TrimLeft(forged, "\x00")), nil
+}
+
+// Bleichenbacher's attack, with a small (256-bit) modulus.
+func Problem47() (string, error) {
+	return bleichenbacher(problemRand(47), 256, "kick it, CC")
+}
+
+// Same thing, with a 768-bit modulus. The only difference is that there are usually several intervals after
+// the first step, which means some time in step 2b.
+func Problem48() (string, error) {
+	return bleichenbacher(problemRand(48), 768, "kick it, CC")
+}
+
+func bleichenbacher(rand io.Reader, bits int, msg string) (string, error) {
+	key, err := rsa.GenerateKey(rand, bits, 3)
+	if err != nil {
+		return "", err
+	}
+	c, err := key.EncryptPKCS1v15(rand, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	oracle := rsa.NewPKCS1Oracle(key)
+	countQueries(oracle, key.Size())
+	m, err := rsa.BleichenbacherAttack(&key.PublicKey, c, oracle)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := rsa.UnpadPKCS1v15(m, key.Size())
+	if err != nil {
+		return "", err
+	}
+	if string(plaintext) != msg {
+		return "", fmt.Errorf("recovered the wrong message: %q", plaintext)
+	}
+	return fmt.Sprintf("Message: %q (%d oracle queries)", plaintext, oracle.Queries()), nil
+}
+
+// Each doubling of the plaintext tells us whether it wrapped around the (odd) modulus, halving the range of
+// possible plaintexts. We use the hollywood callback to see how the message looks halfway through.
+func Problem46() (string, error) {
+	rand := problemRand(46)
+	const msgBase64 = "VGhhdCdzIHdoeSBJIGZvdW5kIHlvdSBkb24ndCBwbGF5IGFyb3VuZCB3aXRoIHRoZSBGdW5reSBDb2xkIE1lZGluYQ=="
+	msg, err := matasano.Base64ToBytes(msgBase64)
+	if err != nil {
+		return "", err
+	}
+	key, err := rsa.GenerateKey(rand, 1024, 65537)
+	if err != nil {
+		return "", err
+	}
+	c := key.Encrypt(new(big.Int).SetBytes(msg))
+
+	var (
+		steps   int
+		halfway []byte
+	)
+	hollywood := func(upper *big.Int) {
+		steps++
+		if steps == key.N.BitLen()/2 {
+			halfway = upper.Bytes()
+		}
+	}
+	oracle := rsa.NewLSBOracle(key)
+	countQueries(oracle, key.Size())
+	m := rsa.ParityAttack(&key.PublicKey, c, oracle, hollywood)
+	if !bytes.Equal(m.Bytes(), msg) {
+		return "", fmt.Errorf("recovered the wrong message: %q", m.Bytes())
+	}
+	return fmt.Sprintf("Message: %q (%d oracle queries; halfway: %q)", m.Bytes(), oracle.Queries(), halfway), nil
+}
+
+// The nonce is only 16 bits, so just try all of them. For each guess at k we can compute x from the
+// signature, and we know we have the right one when g^x = y.
+func Problem43() (string, error) {
+	rand := problemRand(43)
+	const (
+		msg = "For those that envy a MC it can be hazardous to your health\n" +
+			"So be friendly, a matter of life and death, just like a etch-a-sketch\n"
+		y = "84ad4719d044495496a3201c8ff484feb45b962e7302e56a392aee4abab3e4bdebf2955b4736012f21a08084056b19bcd7fee" +
+			"56048e004e44984e2f411788efdc837a0d2e5abb7b555039fd243ac01f0fb2ed1dec568280ce678e931868d23eb095fde9d37" +
+			"79191b8c0299d6e07bbb283e6633451e535c45513b2d33c99ea17"
+		r            = "548099063082341131477253921760299949438196259240"
+		s            = "857042759984254168557880549501802188789837994940"
+		expectedHash = "0954edd5e0afe5542a4adf012611a91912a3ec16"
+	)
+	// First make sure signing and verifying work.
+	key, err := dsa.DefaultParameters.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	sig, err := key.Sign(rand, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	if !key.Verify([]byte(msg), sig) {
+		return "", fmt.Errorf("signature did not verify")
+	}
+
+	pub := &dsa.PublicKey{Parameters: dsa.DefaultParameters, Y: mustBigInt(y, 16)}
+	sig = &dsa.Signature{R: mustBigInt(r, 10), S: mustBigInt(s, 10)}
+	x, err := dsa.RecoverXFromNonceRange(pub, dsa.Hash([]byte(msg)), sig, 0, 1<<16)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.Sum([]byte(x.Text(16)))
+	if matasano.BytesToHex(h[:]) != expectedHash {
+		return "", fmt.Errorf("recovered the wrong key: %x", x)
+	}
+	return fmt.Sprintf("x = %x", x), nil
+}
+
+// Signatures made with the same nonce have the same r. Once we find two of those, we can solve for k and then
+// x. (The challenge comes with a file of signatures; we just make our own.)
+func Problem44() (string, error) {
+	rand := problemRand(44)
+	key, err := dsa.DefaultParameters.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	// The reused nonce can be any value in [1, q), such as another private key.
+	nonceKey, err := dsa.DefaultParameters.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	reused := nonceKey.X
+	var msgs []dsa.SignedMessage
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("Message number %d", i))
+		var sig *dsa.Signature
+		if i == 3 || i == 7 {
+			sig, err = key.SignWithNonce(msg, reused)
+		} else {
+			sig, err = key.Sign(rand, msg)
+		}
+		if err != nil {
+			return "", err
+		}
+		msgs = append(msgs, dsa.SignedMessage{H: dsa.Hash(msg), Sig: sig})
+	}
+	x, i, j, err := dsa.RecoverXFromRepeatedNonce(&key.PublicKey, msgs)
+	if err != nil {
+		return "", err
+	}
+	if x.Cmp(key.X) != 0 {
+		return "", fmt.Errorf("recovered the wrong key")
+	}
+	return fmt.Sprintf("Messages %d and %d share a nonce; x = %x", i, j, x), nil
+}
+
+// If we can make the other side use g = 0, every signature has r = 0, and a verifier that doesn't check r
+// will accept it for any message. With g = p + 1, g^anything = 1, and we can make a signature that works for
+// any message even against a careful verifier.
+func Problem45() (string, error) {
+	rand := problemRand(45)
+	key, err := dsa.DefaultParameters.GenerateKey(rand)
+	if err != nil {
+		return "", err
+	}
+	msgs := [][]byte{[]byte("Hello, world"), []byte("Goodbye, world")}
+
+	zeroG := *dsa.DefaultParameters
+	zeroG.G = big.NewInt(0)
+	zeroKey := zeroG.NewKey(key.X)
+	sig, err := zeroKey.Sign(rand, []byte("anything"))
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range msgs {
+		if !zeroKey.VerifyLoose(msg, sig) {
+			return "", fmt.Errorf("g = 0: signature did not verify for %q", msg)
+		}
+		if zeroKey.Verify(msg, sig) {
+			return "", fmt.Errorf("g = 0: careful verifier accepted r = 0")
+		}
+	}
+
+	pPlusOne := *dsa.DefaultParameters
+	pPlusOne.G = new(big.Int).Add(pPlusOne.P, big.NewInt(1))
+	pub := &dsa.PublicKey{Parameters: &pPlusOne, Y: key.Y}
+	sig, err = dsa.MagicSignature(pub, big.NewInt(1337))
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range msgs {
+		if !pub.Verify(msg, sig) {
+			return "", fmt.Errorf("g = p + 1: magic signature did not verify for %q", msg)
+		}
+	}
+	return "OK", nil
+}
+
+func mustBigInt(s string, base int) *big.Int {
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		panic("bad integer constant: " + s)
+	}
+	return n
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/cbcmac"
+)
+
+func init() {
+	Register(7, 49, "CBC-MAC Message Forgery", Problem49)
+	Register(7, 50, "Hashing with CBC-MAC", Problem50)
+}
+
+// The AES-128 examples from RFC 4493.
+var cmacTestVectors = []struct {
+	msg, tag string
+}{
+	{"", "bb1d6929e95937287fa37d129b756746"},
+	{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+	{
+		"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411",
+		"dfa66747de9ae63030ca32611497c827",
+	},
+	{
+		"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+			"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710",
+		"51f0bebf7e3b9d92fc49741779363cfe",
+	},
+}
+
+// checkCMACResists checks that the length extension that works against the bank's CBC-MAC fails against
+// CMAC (after making sure that this is really CMAC, with the examples above). CMAC masks the last block with
+// a subkey, so its tag isn't the chaining state that Extend relies on.
+func checkCMACResists(rand io.Reader, msg1, msg2 []byte) error {
+	block, err := aes.NewCipher(matasano.MustHexToBytes("2b7e151628aed2a6abf7158809cf4f3c"))
+	if err != nil {
+		return err
+	}
+	for i, tv := range cmacTestVectors {
+		tag := cbcmac.CMAC(block, matasano.MustHexToBytes(tv.msg))
+		if !bytes.Equal(tag, matasano.MustHexToBytes(tv.tag)) {
+			return fmt.Errorf("CMAC example %d: got %x; want %s", i+1, tag, tv.tag)
+		}
+	}
+
+	block, err = aes.NewCipher(matasano.RandomBytes(rand, aes.BlockSize))
+	if err != nil {
+		return err
+	}
+	forged, err := cbcmac.Extend(make([]byte, aes.BlockSize), msg1, cbcmac.CMAC(block, msg1), msg2)
+	if err != nil {
+		return err
+	}
+	if cbcmac.VerifyCMAC(block, forged, cbcmac.CMAC(block, msg2)) {
+		return errors.New("length extension worked against CMAC")
+	}
+	return nil
+}
+
+// Account ids for problem 49. They're all the same length so that swapping one for another doesn't change
+// the block alignment.
+const (
+	victim   = 10
+	attacker = 66
+	someone  = 20
+)
+
+// Steal from the victim's account with each version of the bank API: in the first, by rewriting the first
+// block of a request the attacker signed for their own account using the IV; in the second, by appending a
+// request signed for the attacker's account to one of the victim's, via length extension.
+func Problem49() (string, error) {
+	rand := problemRand(49)
+	bank, err := cbcmac.NewBank(rand, map[int]int{victim: 5000000, attacker: 0, someone: 0})
+	if err != nil {
+		return "", err
+	}
+	const bs = aes.BlockSize
+
+	// Version 1. The attacker signs "from=66&to=66&amount=1000000" (which the bank would refuse, as they have no
+	// money) and changes the first block to "from=10&to=66&am".
+	req := bank.Client(rand, attacker).Transfer(attacker, 1000000)
+	msg, iv, mac := req[:len(req)-2*bs], req[len(req)-2*bs:len(req)-bs], req[len(req)-bs:]
+	from := fmt.Sprintf("from=%d", attacker)
+	newMsg := []byte(strings.Replace(string(msg), from, fmt.Sprintf("from=%d", victim), 1))
+	newIV, err := cbcmac.ForgeIV(iv, msg, newMsg)
+	if err != nil {
+		return "", err
+	}
+	forged := append(append(newMsg, newIV...), mac...)
+	if err := bank.HandleTransfer(forged); err != nil {
+		return "", fmt.Errorf("forged transfer rejected: %s", err)
+	}
+	if got := bank.Balance(attacker); got != 1000000 {
+		return "", fmt.Errorf("after the forged transfer, the attacker has %d", got)
+	}
+
+	// Version 2. The garbage block where the requests join turns the victim's last transaction and the first
+	// of the attacker's into one malformed transaction, which the bank skips; the sacrificial "66:0" absorbs
+	// it. If the garbage happens to contain '&', the bank rejects the whole request, so wait for another one
+	// from the victim.
+	zeroIV := make([]byte, bs)
+	signed := bank.Client(rand, attacker).MultiTransfer([]cbcmac.Tx{
+		{To: attacker, Amount: 0},
+		{To: attacker, Amount: 1000000},
+	})
+	ext, extMAC := signed[:len(signed)-bs], signed[len(signed)-bs:]
+	// An extension shorter than a block can't work (its padding would change), so Extend should refuse it, and
+	// likewise a MAC that isn't a whole block.
+	if _, err := cbcmac.Extend(zeroIV, ext, extMAC, []byte("short")); err == nil {
+		return "", errors.New("Extend accepted a message shorter than a block")
+	}
+	if _, err := cbcmac.Extend(zeroIV, ext, extMAC[:bs-1], ext); err == nil {
+		return "", errors.New("Extend accepted a MAC shorter than a block")
+	}
+	for i := 0; i < 10; i++ {
+		captured := bank.Client(rand, victim).MultiTransfer([]cbcmac.Tx{{To: someone, Amount: 100 + i}})
+		msg1, mac1 := captured[:len(captured)-bs], captured[len(captured)-bs:]
+		forged, err := cbcmac.Extend(zeroIV, msg1, mac1, ext)
+		if err != nil {
+			return "", err
+		}
+		// The forgery's MAC is the one the attacker got for ext.
+		forged = append(forged, extMAC...)
+		if _, err := bank.HandleMultiTransfer(forged); err != nil {
+			continue
+		}
+		if got := bank.Balance(attacker); got != 2000000 {
+			return "", fmt.Errorf("after the extended transfer, the attacker has %d", got)
+		}
+		if err := checkCMACResists(rand, msg1, ext); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Stole 1000000 with each API version (but not with CMAC); forged extension %q", forged), nil
+	}
+	return "", errors.New("every length-extended request was rejected")
+}
+
+// Find a JavaScript snippet that starts with an alert of our choosing and has the same CBC-MAC "hash" as the
+// original, without any newlines (so that the trailing comment hides the rest).
+func Problem50() (string, error) {
+	block, err := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aes.BlockSize)
+	original := []byte("alert('MZA who was that?');\n")
+	hash := cbcmac.MAC(block, iv, original)
+	if want := matasano.MustHexToBytes("296b8d7cb78a243dda4d0a61d33bbdd1"); !bytes.Equal(hash, want) {
+		return "", fmt.Errorf("got hash %x; want %x", hash, want)
+	}
+	// Tweak the padding of the prefix (it's inside the comment) until the glue block has no line breaks.
+	for i := 0; i < 1000; i++ {
+		prefix := []byte(fmt.Sprintf("alert('Ayo, the Wu is back!');//%d", i))
+		if n := len(prefix) % aes.BlockSize; n > 0 {
+			prefix = append(prefix, bytes.Repeat([]byte{' '}, aes.BlockSize-n)...)
+		}
+		forged, err := cbcmac.SecondPreimage(block, iv, prefix, original)
+		if err != nil {
+			return "", err
+		}
+		if bytes.ContainsAny(forged[:len(forged)-len(original)+aes.BlockSize], "\n\r") {
+			continue
+		}
+		if !bytes.Equal(cbcmac.MAC(block, iv, forged), hash) {
+			return "", errors.New("forged snippet has a different hash")
+		}
+		return fmt.Sprintf("%q hashes to %x", forged, hash), nil
+	}
+	return "", errors.New("couldn't find a glue block without line breaks")
+}
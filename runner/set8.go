@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"fmt"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/gcm"
+)
+
+func init() {
+	Register(8, 63, "Key-Recovery Attacks on GCM with Repeated Nonces", Problem63)
+}
+
+// Test cases 1-4 from the GCM spec (McGrew and Viega, "The Galois/Counter Mode of Operation"), which NIST
+// SP 800-38D references.
+var gcmTestVectors = []struct {
+	key, nonce, plaintext, additionalData, ciphertext, tag string
+}{
+	{
+		key:   "00000000000000000000000000000000",
+		nonce: "000000000000000000000000",
+		tag:   "58e2fccefa7e3061367f1d57a4e7455a",
+	},
+	{
+		key:        "00000000000000000000000000000000",
+		nonce:      "000000000000000000000000",
+		plaintext:  "00000000000000000000000000000000",
+		ciphertext: "0388dace60b6a392f328c2b971b2fe78",
+		tag:        "ab6e47d42cec13bdf53a67b21257bddf",
+	},
+	{
+		key:   "feffe9928665731c6d6a8f9467308308",
+		nonce: "cafebabefacedbaddecaf888",
+		plaintext: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255",
+		ciphertext: "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+		tag: "4d5c2af327cd64a62cf35abd2ba6fab4",
+	},
+	{
+		key:   "feffe9928665731c6d6a8f9467308308",
+		nonce: "cafebabefacedbaddecaf888",
+		plaintext: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+		additionalData: "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+		ciphertext: "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+		tag: "5bc94fbc3221a5db94fae95ae7121a47",
+	},
+}
+
+func checkGCMTestVectors() error {
+	for i, tv := range gcmTestVectors {
+		var (
+			key            = matasano.MustHexToBytes(tv.key)
+			nonce          = matasano.MustHexToBytes(tv.nonce)
+			plaintext      = matasano.MustHexToBytes(tv.plaintext)
+			additionalData = matasano.MustHexToBytes(tv.additionalData)
+			ciphertext     = matasano.MustHexToBytes(tv.ciphertext)
+			tag            = matasano.MustHexToBytes(tv.tag)
+		)
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		aead, err := gcm.New(block)
+		if err != nil {
+			return err
+		}
+		sealed := aead.Seal(nil, nonce, plaintext, additionalData)
+		if want := append(ciphertext, tag...); !bytes.Equal(sealed, want) {
+			return fmt.Errorf("test case %d: got %x; want %x", i+1, sealed, want)
+		}
+		opened, err := aead.Open(nil, nonce, sealed, additionalData)
+		if err != nil {
+			return fmt.Errorf("test case %d: %s", i+1, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			return fmt.Errorf("test case %d: decrypted to %x", i+1, opened)
+		}
+	}
+	return nil
+}
+
+// After checking the GCM implementation against the test vectors, seal a few messages with a repeated nonce,
+// recover the authentication key from them, and forge a tag for a tampered message.
+func Problem63() (string, error) {
+	if err := checkGCMTestVectors(); err != nil {
+		return "", err
+	}
+
+	rand := problemRand(63)
+	key := matasano.RandomBytes(rand, 16)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	aead, err := gcm.New(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := matasano.RandomBytes(rand, gcm.NonceSize)
+	var msgs []gcm.Message
+	for _, s := range []string{
+		"Transfer $10 to Alice",
+		"Transfer $20 to Bob, who has a rather longer name",
+		"Transfer $30 to Carol",
+	} {
+		additionalData := []byte("account 12345")
+		m, err := gcm.SplitSealed(additionalData, aead.Seal(nil, nonce, []byte(s), additionalData))
+		if err != nil {
+			return "", err
+		}
+		msgs = append(msgs, m)
+	}
+	if _, err := gcm.SplitSealed(nil, make([]byte, gcm.TagSize-1)); err == nil {
+		return "", fmt.Errorf("expected SplitSealed to reject a message shorter than a tag")
+	}
+
+	candidates, err := gcm.RecoverAuthKey(msgs, rand)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) != 1 {
+		return "", fmt.Errorf("got %d candidates for the authentication key; expected 1", len(candidates))
+	}
+	h := make([]byte, 16)
+	block.Encrypt(h, h)
+	if !bytes.Equal(candidates[0].Bytes(), h) {
+		return "", fmt.Errorf("recovered the wrong authentication key")
+	}
+
+	// We know the plaintext of the first message, so flip some ciphertext bits to change it (this is CTR mode,
+	// after all) and then fix up the tag.
+	forger := gcm.NewForger(candidates[0], msgs[0])
+	tampered := append([]byte{}, msgs[0].Ciphertext...)
+	delta, err := matasano.Xor([]byte("$10 to Alice"), []byte("$99 to Mallo"))
+	if err != nil {
+		return "", err
+	}
+	for i, d := range delta {
+		tampered[len("Transfer ")+i] ^= d
+	}
+	additionalData := []byte("account 66666")
+	sealed := append(tampered, forger.Tag(additionalData, tampered)...)
+	opened, err := aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("forgery rejected: %s", err)
+	}
+	return fmt.Sprintf("Recovered H = %x; forged %q", h, opened), nil
+}
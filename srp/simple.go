@@ -0,0 +1,145 @@
+package srp
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+)
+
+// This is the simplified SRP from http://cryptopals.com/sets/5/challenges/38/. Because B doesn't depend on
+// the password verifier, a man-in-the-middle posing as the server can choose b, B, u, and the salt, and then
+// mount an offline dictionary attack on the client's proof.
+
+// SimpleServer is a simplified SRP server. It only uses N and G from its Params.
+type SimpleServer struct {
+	*Server
+}
+
+// NewSimpleServer creates a server that uses rand (crypto/rand if nil) for salts, u, and its secret
+// exponents.
+func NewSimpleServer(rand io.Reader, params *Params) *SimpleServer {
+	return &SimpleServer{NewServer(rand, params)}
+}
+
+// ServeConn handles a single simplified-SRP login attempt on conn.
+func (s *SimpleServer) ServeConn(conn io.ReadWriter) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	p := s.params
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if h.A == nil {
+		return errors.New("missing A")
+	}
+	u, ok := s.users[h.Email]
+	if !ok {
+		return fmt.Errorf("unknown user %q", h.Email)
+	}
+	b, err := matasano.RandomNonzero(s.rand, p.N)
+	if err != nil {
+		return err
+	}
+	uH, err := matasano.RandomNonzero(s.rand, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	B := new(big.Int).Exp(p.G, b, p.N)
+	if err := enc.Encode(challenge{Salt: u.salt, B: B, U: uH}); err != nil {
+		return err
+	}
+
+	// S = (A * v^u)^b
+	S := new(big.Int).Exp(u.v, uH, p.N)
+	S.Mul(S, h.A)
+	S.Exp(S, b, p.N)
+	return checkProof(enc, dec, S, u.salt)
+}
+
+// SimpleLogin runs the client side of simplified SRP on conn, using rand (crypto/rand if nil) for its secret
+// exponent.
+func SimpleLogin(rand io.Reader, conn io.ReadWriter, params *Params, email, password string) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	p := params
+
+	a, err := matasano.RandomNonzero(rand, p.N)
+	if err != nil {
+		return err
+	}
+	A := new(big.Int).Exp(p.G, a, p.N)
+	if err := enc.Encode(hello{Email: email, A: A}); err != nil {
+		return err
+	}
+	var c challenge
+	if err := dec.Decode(&c); err != nil {
+		return err
+	}
+	if c.B == nil || c.U == nil || zeroModN(c.B, p.N) {
+		return errBadChallenge
+	}
+
+	// S = B^(a + u * x)
+	x := hashInt(c.Salt, []byte(password))
+	exp := new(big.Int).Mul(c.U, x)
+	exp.Add(exp, a)
+	S := new(big.Int).Exp(c.B, exp, p.N)
+	return sendProof(enc, dec, S, c.Salt)
+}
+
+// MaliciousSimpleServer poses as a simplified SRP server. It sends b = 1 (so B = g), u = 1, and an empty
+// salt, and records what the client sends. Then S = A * v = A * g^x, so each password guess costs only one
+// modular exponentiation to check.
+type MaliciousSimpleServer struct {
+	params *Params
+
+	a   *big.Int
+	mac []byte
+}
+
+func NewMaliciousSimpleServer(params *Params) *MaliciousSimpleServer {
+	return &MaliciousSimpleServer{params: params}
+}
+
+// ServeConn captures a login attempt on conn. It always tells the client that the login failed.
+func (s *MaliciousSimpleServer) ServeConn(conn io.ReadWriter) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if err := enc.Encode(challenge{Salt: []byte{}, B: s.params.G, U: big.NewInt(1)}); err != nil {
+		return err
+	}
+	var pr proof
+	if err := dec.Decode(&pr); err != nil {
+		return err
+	}
+	s.a = h.A
+	s.mac = pr.MAC
+	return enc.Encode(result{OK: false})
+}
+
+// Crack tries each password in dictionary against the captured login attempt.
+func (s *MaliciousSimpleServer) Crack(dictionary []string) (string, error) {
+	if s.a == nil {
+		return "", errors.New("no login attempt captured")
+	}
+	for _, password := range dictionary {
+		S := new(big.Int).Mul(s.a, verifier(s.params, nil, password))
+		S.Mod(S, s.params.N)
+		if hmac.Equal(s.mac, proofMAC(S, nil)) {
+			return password, nil
+		}
+	}
+	return "", errors.New("password not found in dictionary")
+}
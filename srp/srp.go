@@ -0,0 +1,237 @@
+// Package srp implements Secure Remote Password authentication as described at
+// http://cryptopals.com/sets/5/challenges/36/, along with the attacks from /37/ and /38/.
+//
+// The client and server talk over any io.ReadWriter (for instance, either end of a net.Pipe) using JSON
+// messages.
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cespare/matasano"
+	"github.com/cespare/matasano/dh"
+)
+
+// Params are the values agreed upon in advance by the client and server.
+type Params struct {
+	N *big.Int // Prime modulus
+	G *big.Int // Generator
+	K *big.Int // Multiplier
+}
+
+// NewParams returns the SRP-6a parameters for N and g, in which k = H(N, PAD(g)) (as in RFC 5054, but with
+// SHA256 like the rest of this package). The challenge uses SRP-6's k = 3.
+func NewParams(N, g *big.Int) *Params {
+	padded := g.FillBytes(make([]byte, (N.BitLen()+7)/8))
+	return &Params{N: N, G: g, K: hashInt(N.Bytes(), padded)}
+}
+
+// DefaultParams uses the NIST prime from package dh with g = 2.
+var DefaultParams = NewParams(dh.DefaultGroup.P, big.NewInt(2))
+
+// ErrAuthFailed is returned by both sides when a login attempt is rejected.
+var ErrAuthFailed = errors.New("authentication failed")
+
+// Protocol messages.
+type (
+	hello struct {
+		Email string
+		A     *big.Int
+	}
+	challenge struct {
+		Salt []byte
+		B    *big.Int
+		U    *big.Int `json:",omitempty"` // Only used by simplified SRP
+	}
+	proof struct {
+		MAC []byte
+	}
+	result struct {
+		OK bool
+	}
+)
+
+type user struct {
+	salt []byte
+	v    *big.Int
+}
+
+// Server is an SRP server with a set of registered users.
+type Server struct {
+	params *Params
+	rand   io.Reader
+	users  map[string]user
+}
+
+// NewServer creates a server that uses rand (crypto/rand if nil) for salts and its secret exponents.
+func NewServer(rand io.Reader, params *Params) *Server {
+	return &Server{params: params, rand: rand, users: make(map[string]user)}
+}
+
+// Register adds a user. The server only stores the salt and verifier, not the password.
+func (s *Server) Register(email, password string) error {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(matasano.RandOrDefault(s.rand), salt); err != nil {
+		return err
+	}
+	s.users[email] = user{salt: salt, v: verifier(s.params, salt, password)}
+	return nil
+}
+
+// ServeConn handles a single login attempt on conn. It returns ErrAuthFailed if the client's proof is
+// incorrect.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	p := s.params
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if h.A == nil {
+		return errors.New("missing A")
+	}
+	// Note that we don't check that A % N != 0. See LoginWithoutPassword.
+	u, ok := s.users[h.Email]
+	if !ok {
+		return fmt.Errorf("unknown user %q", h.Email)
+	}
+	b, err := matasano.RandomNonzero(s.rand, p.N)
+	if err != nil {
+		return err
+	}
+	// B = kv + g^b
+	B := new(big.Int).Mul(p.K, u.v)
+	B.Add(B, new(big.Int).Exp(p.G, b, p.N))
+	B.Mod(B, p.N)
+	if err := enc.Encode(challenge{Salt: u.salt, B: B}); err != nil {
+		return err
+	}
+
+	// S = (A * v^u)^b
+	uH := hashInts(h.A, B)
+	S := new(big.Int).Exp(u.v, uH, p.N)
+	S.Mul(S, h.A)
+	S.Exp(S, b, p.N)
+	return checkProof(enc, dec, S, u.salt)
+}
+
+// Login runs the client side of the protocol on conn, using rand (crypto/rand if nil) for its secret
+// exponent. It returns nil if the server accepts the login.
+func Login(rand io.Reader, conn io.ReadWriter, params *Params, email, password string) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	p := params
+
+	a, err := matasano.RandomNonzero(rand, p.N)
+	if err != nil {
+		return err
+	}
+	A := new(big.Int).Exp(p.G, a, p.N)
+	if err := enc.Encode(hello{Email: email, A: A}); err != nil {
+		return err
+	}
+	var c challenge
+	if err := dec.Decode(&c); err != nil {
+		return err
+	}
+	// If B = 0 (mod N), then S would be 0 (the mirror image of LoginWithoutPassword).
+	if c.B == nil || zeroModN(c.B, p.N) {
+		return errBadChallenge
+	}
+
+	// S = (B - k * g^x)^(a + u * x)
+	uH := hashInts(A, c.B)
+	x := hashInt(c.Salt, []byte(password))
+	base := new(big.Int).Exp(p.G, x, p.N)
+	base.Mul(base, p.K)
+	base.Sub(c.B, base)
+	base.Mod(base, p.N)
+	exp := new(big.Int).Mul(uH, x)
+	exp.Add(exp, a)
+	S := new(big.Int).Exp(base, exp, p.N)
+	return sendProof(enc, dec, S, c.Salt)
+}
+
+// LoginWithoutPassword is the attack from #37. It sends A = multiple * N, which makes the server's S zero
+// no matter what the password is, so the client can compute K without knowing it.
+func LoginWithoutPassword(conn io.ReadWriter, params *Params, email string, multiple int64) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	A := new(big.Int).Mul(params.N, big.NewInt(multiple))
+	if err := enc.Encode(hello{Email: email, A: A}); err != nil {
+		return err
+	}
+	var c challenge
+	if err := dec.Decode(&c); err != nil {
+		return err
+	}
+	return sendProof(enc, dec, big.NewInt(0), c.Salt)
+}
+
+// sendProof sends HMAC-SHA256(SHA256(S), salt) and reads the server's verdict.
+func sendProof(enc *json.Encoder, dec *json.Decoder, S *big.Int, salt []byte) error {
+	if err := enc.Encode(proof{MAC: proofMAC(S, salt)}); err != nil {
+		return err
+	}
+	var r result
+	if err := dec.Decode(&r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// checkProof reads the client's proof, checks it against S, and tells the client the result.
+func checkProof(enc *json.Encoder, dec *json.Decoder, S *big.Int, salt []byte) error {
+	var pr proof
+	if err := dec.Decode(&pr); err != nil {
+		return err
+	}
+	ok := hmac.Equal(pr.MAC, proofMAC(S, salt))
+	if err := enc.Encode(result{OK: ok}); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+func proofMAC(S *big.Int, salt []byte) []byte {
+	K := sha256.Sum256(S.Bytes())
+	mac := hmac.New(sha256.New, K[:])
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// verifier computes v = g^x where x = SHA256(salt|password).
+func verifier(p *Params, salt []byte, password string) *big.Int {
+	return new(big.Int).Exp(p.G, hashInt(salt, []byte(password)), p.N)
+}
+
+// hashInt returns SHA256 of the concatenation of bufs as an integer.
+func hashInt(bufs ...[]byte) *big.Int {
+	h := sha256.New()
+	for _, b := range bufs {
+		h.Write(b)
+	}
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
+
+func hashInts(a, b *big.Int) *big.Int { return hashInt(a.Bytes(), b.Bytes()) }
+
+var errBadChallenge = errors.New("malformed challenge")
+
+// zeroModN reports whether x = 0 (mod n).
+func zeroModN(x, n *big.Int) bool { return new(big.Int).Mod(x, n).Sign() == 0 }
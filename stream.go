@@ -0,0 +1,72 @@
+package matasano
+
+import "crypto/cipher"
+
+// As with CBC, these are from scratch rather than using crypto/cipher's versions.
+
+// ctr is counter mode: the keystream is the encryption of successive values of a counter, starting at the IV
+// and incremented as a big-endian integer the size of a block.
+type ctr struct {
+	b       cipher.Block
+	counter []byte
+	stream  []byte
+	used    int // Bytes of stream already used
+}
+
+func NewCTR(b cipher.Block, iv []byte) cipher.Stream {
+	if len(iv) != b.BlockSize() {
+		panic("IV must have length equal to blocksize.")
+	}
+	return &ctr{
+		b:       b,
+		counter: append([]byte{}, iv...),
+		stream:  make([]byte, b.BlockSize()),
+		used:    b.BlockSize(),
+	}
+}
+
+func (c *ctr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == len(c.stream) {
+			c.b.Encrypt(c.stream, c.counter)
+			for j := len(c.counter) - 1; j >= 0; j-- {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.used]
+		c.used++
+	}
+}
+
+// ofb is output feedback mode: the keystream is the IV encrypted over and over.
+type ofb struct {
+	b      cipher.Block
+	stream []byte
+	used   int
+}
+
+func NewOFB(b cipher.Block, iv []byte) cipher.Stream {
+	if len(iv) != b.BlockSize() {
+		panic("IV must have length equal to blocksize.")
+	}
+	return &ofb{
+		b:      b,
+		stream: append([]byte{}, iv...),
+		used:   b.BlockSize(),
+	}
+}
+
+func (o *ofb) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if o.used == len(o.stream) {
+			o.b.Encrypt(o.stream, o.stream)
+			o.used = 0
+		}
+		dst[i] = src[i] ^ o.stream[o.used]
+		o.used++
+	}
+}
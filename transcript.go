@@ -0,0 +1,107 @@
+package matasano
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A TranscriptEntry is one query to an Oracle and its response. Transcripts are stored as JSON lines (one
+// entry per line; the byte slices are base64-encoded).
+type TranscriptEntry struct {
+	Input  []byte `json:"input"`
+	Output []byte `json:"output,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+// RecordingOracle wraps an Oracle and writes a transcript of every query to W.
+type RecordingOracle struct {
+	Oracle
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewRecordingOracle(oracle Oracle, w io.Writer) *RecordingOracle {
+	return &RecordingOracle{Oracle: oracle, enc: json.NewEncoder(w)}
+}
+
+// Encrypt passes the query on to the wrapped Oracle and records the result. If the transcript can't be
+// written, it returns that error instead, since a silently incomplete transcript isn't much use.
+func (o *RecordingOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	encrypted, err := o.Oracle.Encrypt(plaintext)
+	entry := TranscriptEntry{Input: plaintext, Output: encrypted}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if werr := o.enc.Encode(entry); werr != nil {
+		return nil, fmt.Errorf("cannot record oracle transcript: %s", werr)
+	}
+	return encrypted, err
+}
+
+var ErrUnexpectedQuery = errors.New("query not in transcript")
+
+// ReplayOracle answers queries from a transcript made by a RecordingOracle, so that an attack can be rerun
+// deterministically without the original oracle (and its random key). Repeated queries with the same input
+// get the recorded responses in order; a query that isn't in the transcript (or has been asked more times than
+// it was recorded) is an error wrapping ErrUnexpectedQuery.
+type ReplayOracle struct {
+	mu        sync.Mutex
+	responses map[string][]TranscriptEntry
+}
+
+// NewReplayOracle reads a transcript from r.
+func NewReplayOracle(r io.Reader) (*ReplayOracle, error) {
+	o := &ReplayOracle{responses: make(map[string][]TranscriptEntry)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		var entry TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("bad transcript entry on line %d: %s", line, err)
+		}
+		key := string(entry.Input)
+		o.responses[key] = append(o.responses[key], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *ReplayOracle) Encrypt(plaintext []byte) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := string(plaintext)
+	entries := o.responses[key]
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %x", ErrUnexpectedQuery, plaintext)
+	}
+	entry := entries[0]
+	if len(entries) == 1 {
+		delete(o.responses, key)
+	} else {
+		o.responses[key] = entries[1:]
+	}
+	if entry.Err != "" {
+		return nil, errors.New(entry.Err)
+	}
+	return entry.Output, nil
+}
+
+// Remaining returns the number of recorded responses that haven't been replayed yet.
+func (o *ReplayOracle) Remaining() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, entries := range o.responses {
+		n += len(entries)
+	}
+	return n
+}